@@ -2,10 +2,16 @@ module github.com/fuad-daoud/release-aur
 
 go 1.25.3
 
-require github.com/stretchr/testify v1.11.1
+require (
+	github.com/ProtonMail/go-crypto v1.3.0
+	github.com/stretchr/testify v1.11.1
+)
 
 require (
+	github.com/cloudflare/circl v1.6.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/crypto v0.33.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
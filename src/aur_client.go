@@ -1,103 +1,393 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
+
+	"github.com/fuad-daoud/release-aur/src/parser"
 )
 
+// aurDefaults are used for every AUR setting left empty.
+var aurDefaults = struct {
+	base, rpcPath, rpcVersion, plainURL string
+}{
+	base:       "https://aur.archlinux.org",
+	rpcPath:    "/rpc/",
+	rpcVersion: "5",
+	plainURL:   "/cgit/aur.git/plain/{file}?h={pkgname}",
+}
+
+// githubAurMirror is the raw file URL of the AUR mirror on GitHub, which keeps
+// every package on a branch named after it.
+const githubAurMirror = "https://raw.githubusercontent.com/archlinux/aur/{pkgname}/{file}"
+
 type Client struct {
 	base              string
+	rpcPath           string
+	rpcVersion        string
+	plainURL          string
+	mirrorURL         string
 	client            *http.Client
 	tries             int
 	waitRetryDuration time.Duration
+	maxWaitDuration   time.Duration
+	cache             *httpCache
+	aurLimiter        *rateLimiter
+	aurInfo           *aurInfoCache
 }
 
 func NewClient(timeout, waitRetryDuration time.Duration, tries int) Client {
 	return Client{
-		base:              "https://aur.archlinux.org",
+		base:              aurDefaults.base,
 		tries:             max(tries, 1),
 		waitRetryDuration: max(100*time.Millisecond, waitRetryDuration),
+		maxWaitDuration:   time.Minute,
+		aurLimiter:        newRateLimiter(aurRequestInterval),
+		aurInfo:           &aurInfoCache{data: map[string]AurData{}},
 		client: &http.Client{
 			Timeout: timeout,
 		},
 	}
 }
 
+// WithAur returns a copy of the client talking to the AUR, or an aurweb
+// instance, at base. plainURL is the raw file URL pattern with {pkgname} and
+// {file} placeholders, relative to base unless it is a full URL. When mirrorURL
+// is set, in the same pattern form, files are read from it while the AUR is down.
+// Empty values keep the defaults.
+func (client Client) WithAur(base, rpcPath, rpcVersion, plainURL, mirrorURL string) Client {
+	client.base = cmp.Or(strings.TrimSuffix(base, "/"), client.base, aurDefaults.base)
+	client.rpcPath = rpcPath
+	client.rpcVersion = rpcVersion
+	client.plainURL = plainURL
+	client.mirrorURL = mirrorURL
+	return client
+}
+
+// WithCache returns a copy of the client that keeps successful downloads in
+// dir and revalidates them on the next run, an empty dir disables the cache.
+func (client Client) WithCache(dir string) Client {
+	client.cache = nil
+	if dir != "" {
+		client.cache = newHTTPCache(dir)
+	}
+	return client
+}
+
 type AurResponse struct {
-	Resultcount int `json:"resultcount"`
-	Results     []struct {
-		Name    string `json:"Name"`
-		Version string `json:"Version"`
-	} `json:"results"`
+	Version     int          `json:"version"`
+	Type        string       `json:"type"`
+	Error       string       `json:"error"`
+	Resultcount int          `json:"resultcount"`
+	Results     []AurPackage `json:"results"`
 }
+
+// AurPackage is a result of the RPC info request.
+type AurPackage struct {
+	ID             int      `json:"ID"`
+	Name           string   `json:"Name"`
+	PackageBaseID  int      `json:"PackageBaseID"`
+	PackageBase    string   `json:"PackageBase"`
+	Version        string   `json:"Version"`
+	Description    string   `json:"Description"`
+	URL            string   `json:"URL"`
+	NumVotes       int      `json:"NumVotes"`
+	Popularity     float64  `json:"Popularity"`
+	OutOfDate      int64    `json:"OutOfDate"`
+	Maintainer     string   `json:"Maintainer"`
+	CoMaintainers  []string `json:"CoMaintainers"`
+	Submitter      string   `json:"Submitter"`
+	FirstSubmitted int64    `json:"FirstSubmitted"`
+	LastModified   int64    `json:"LastModified"`
+	URLPath        string   `json:"URLPath"`
+	Depends        []string `json:"Depends"`
+	MakeDepends    []string `json:"MakeDepends"`
+	OptDepends     []string `json:"OptDepends"`
+	CheckDepends   []string `json:"CheckDepends"`
+	Conflicts      []string `json:"Conflicts"`
+	Provides       []string `json:"Provides"`
+	Replaces       []string `json:"Replaces"`
+	Groups         []string `json:"Groups"`
+	License        []string `json:"License"`
+	Keywords       []string `json:"Keywords"`
+}
+
 type AurData struct {
 	version string
-	pkgrel  int
+	pkgrel  string
 	new     bool
+	// info is the full RPC result, it is empty when the version was read
+	// from the mirror
+	info AurPackage
 }
 
-func (client Client) Get(url string) ([]byte, error) {
+// Get downloads url, retrying failures that are likely to go away. The
+// requests and the waits between them are bound to ctx.
+func (client Client) Get(ctx context.Context, url string) ([]byte, error) {
 	if client.client == nil {
 		client.client = &http.Client{Timeout: 30 * time.Second}
 	}
 
-	var resp *http.Response
-	var err error
-	for client.tries > 0 {
-		resp, err = client.client.Get(url)
+	for attempt := 1; ; attempt++ {
+		body, retryAfter, err := client.do(ctx, url)
+		if err == nil {
+			return body, nil
+		}
+		if retryAfter < 0 {
+			return []byte{}, err
+		}
+		if attempt >= client.tries {
+			return []byte{}, fmt.Errorf("%w: %w", ErrRemoteUnavailable, err)
+		}
+
+		// a Retry-After longer than maxWaitDuration would stall the run
+		wait := min(max(retryAfter, client.backoff(attempt)), client.maxWait())
+		slog.Warn("Request failed trying again", "url", url, "err", err, "duration before retry", wait, "tries left", client.tries-attempt)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return []byte{}, fmt.Errorf("%w, last error: %v", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// do makes a single request. A negative retryAfter means the error is final,
+// otherwise it is the minimum wait the server asked for before trying again.
+func (client Client) do(ctx context.Context, url string) ([]byte, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, -1, err
+	}
+	var cached cacheEntry
+	var cachedBody []byte
+	hit := false
+	if client.cache != nil {
+		if cached, cachedBody, hit = client.cache.load(url); hit {
+			cached.revalidate(req)
+		}
+	}
+	resp, err := client.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, -1, err
+		}
+		if !isRetryableError(err) {
+			// the server could not be reached at all, like a refused connection
+			return nil, -1, fmt.Errorf("%w: %w", ErrRemoteUnavailable, err)
+		}
+		return nil, 0, err
+	}
+	defer func() {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+	}()
 
+	if resp.StatusCode == http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return []byte{}, err
+			if ctx.Err() != nil {
+				return nil, -1, err
+			}
+			return nil, 0, err
+		}
+		if client.cache != nil {
+			if err := client.cache.store(url, resp.Header, body); err != nil {
+				slog.Warn("Failed to cache download", "url", url, "err", err)
+			}
 		}
+		return body, 0, nil
+	}
+	if resp.StatusCode == http.StatusNotModified && hit {
+		slog.Info("Using cached download", "url", url)
+		return cachedBody, 0, nil
+	}
+
+	err = statusError{StatusCode: resp.StatusCode}
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return nil, -1, err
+	}
+	return nil, parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()), err
+}
+
+// statusError is returned for every response that is not 200 OK.
+type statusError struct {
+	StatusCode int
+}
+
+func (err statusError) Error() string {
+	return fmt.Sprintf("Error integrating got none 200 status %v", err.StatusCode)
+}
+
+// backoff doubles the wait for every attempt up to maxWaitDuration and picks
+// a random duration in its upper half so parallel runs do not retry in step.
+func (client Client) backoff(attempt int) time.Duration {
+	limit := client.maxWait()
+	wait := client.waitRetryDuration
+	for i := 1; i < attempt && wait < limit; i++ {
+		wait *= 2
+	}
+	wait = min(wait, limit)
+	if wait <= 1 {
+		return wait
+	}
+	return wait/2 + rand.N(wait/2)
+}
+
+// maxWait is the longest wait before a retry.
+func (client Client) maxWait() time.Duration {
+	if client.maxWaitDuration <= 0 {
+		return time.Minute
+	}
+	return client.maxWaitDuration
+}
 
-		if resp.StatusCode == 200 {
-			break
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+		return max(0, time.Duration(seconds)*time.Second)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(0, date.Sub(now))
+	}
+	return 0
+}
+
+// isRetryableError reports whether a transport error is likely to go away on
+// its own, like a timeout, a temporary DNS failure or a reset connection. A
+// host that does not exist or refuses the connection fails right away.
+func isRetryableError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound && (dnsErr.IsTimeout || dnsErr.IsTemporary)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+// Checksum returns the sha256 of the last body Get returned for url when the
+// cache already calculated it.
+func (client Client) Checksum(url string) (string, bool) {
+	if client.cache == nil {
+		return "", false
+	}
+	return client.cache.checksum(url)
+}
+
+// memoizeGet keeps the bodies of successful downloads so every URL is fetched once.
+func memoizeGet(get func(string) ([]byte, error)) func(string) ([]byte, error) {
+	bodies := make(map[string][]byte)
+	return func(url string) ([]byte, error) {
+		if body, ok := bodies[url]; ok {
+			return body, nil
 		}
-		client.tries--
-		slog.Warn("Got wrong status trying again", "duration before retry", client.waitRetryDuration, "tries left", client.tries)
-		if client.tries == 0 {
-			break
+		body, err := get(url)
+		if err == nil {
+			bodies[url] = body
 		}
-		time.Sleep(client.waitRetryDuration)
+		return body, err
 	}
-	if resp.StatusCode != 200 {
-		return []byte{}, fmt.Errorf("Error integrating got none 200 status %v\n", resp.StatusCode)
+}
+
+func (client Client) getAur(ctx context.Context, path string) ([]byte, error) {
+	if err := client.aurLimiter.wait(ctx); err != nil {
+		return nil, err
 	}
+	return client.Get(ctx, client.base+path)
+}
 
-	defer resp.Body.Close()
+func expandAurPattern(pattern, pkgName, file string) string {
+	return strings.NewReplacer("{pkgname}", pkgName, "{file}", file).Replace(pattern)
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return []byte{}, err
+// isUnavailable reports whether err means the AUR could not answer at all,
+// rather than answering that something does not exist.
+func isUnavailable(err error) bool {
+	var status statusError
+	if errors.As(err, &status) {
+		return status.StatusCode >= 500 || status.StatusCode == http.StatusTooManyRequests
 	}
-	return body, nil
+	return !errors.Is(err, context.Canceled)
 }
 
-func (client Client) getAur(path string) ([]byte, error) {
-	return client.Get(client.base + path)
+// fetchAurFile downloads file from the git repository of pkgName.
+func (client Client) fetchAurFile(ctx context.Context, pkgName, file string) ([]byte, error) {
+	path := expandAurPattern(cmp.Or(client.plainURL, aurDefaults.plainURL), pkgName, file)
+	var body []byte
+	var err error
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		body, err = client.Get(ctx, path)
+	} else {
+		body, err = client.getAur(ctx, path)
+	}
+	if err != nil && client.mirrorURL != "" && isUnavailable(err) {
+		slog.Warn("AUR is unavailable, reading from the mirror", "file", file, "err", err)
+		return client.Get(ctx, expandAurPattern(client.mirrorURL, pkgName, file))
+	}
+	return body, err
 }
 
-func (client Client) fetchPKGBUILD(pkgName string) (string, error) {
-	body, err := client.getAur("/cgit/aur.git/plain/PKGBUILD?h=" + pkgName)
+func (client Client) fetchPKGBUILD(ctx context.Context, pkgName string) (string, error) {
+	body, err := client.fetchAurFile(ctx, pkgName, "PKGBUILD")
 	return string(body), err
 }
 
-func (client Client) getAurPackageVersions(pkgName string) (AurData, error) {
-	body, err := client.getAur("/rpc/?v=5&type=info&arg[]=" + pkgName)
+func (client Client) rpcInfoPath() string {
+	rpcPath := cmp.Or(client.rpcPath, aurDefaults.rpcPath)
+	rpcVersion := cmp.Or(client.rpcVersion, aurDefaults.rpcVersion)
+	return rpcPath + "?v=" + rpcVersion + "&type=info"
+}
+
+func decodeAurResponse(body []byte) (AurResponse, error) {
+	var result AurResponse
+	err := json.Unmarshal(body, &result)
+	if err != nil {
+		slog.Error("could not parse", "jsonString", string(body))
+		return result, fmt.Errorf("Could not unmarshal the response: %v", err)
+	}
+	if result.Type == "error" {
+		return result, fmt.Errorf("AUR RPC error: %s", result.Error)
+	}
+	return result, nil
+}
+
+func (client Client) getAurPackageVersions(ctx context.Context, pkgName string) (AurData, error) {
+	if data, ok := client.aurInfo.get(pkgName); ok {
+		return data, nil
+	}
+	body, err := client.getAur(ctx, client.rpcInfoPath()+"&arg[]="+url.QueryEscape(pkgName))
 	if err != nil {
+		if client.mirrorURL != "" && isUnavailable(err) {
+			slog.Warn("AUR RPC is unavailable, reading the version from the mirror", "err", err)
+			return client.getMirrorPackageVersions(ctx, pkgName)
+		}
 		return AurData{}, err
 	}
-	var result AurResponse
-	err = json.Unmarshal(body, &result)
+	result, err := decodeAurResponse(body)
 	if err != nil {
-		slog.Error("could not parse", "jsonString", string(body))
-		return AurData{}, fmt.Errorf("Could not unmarshal the response: %v\n", err)
+		return AurData{}, err
 	}
 	if result.Resultcount > 1 {
 		return AurData{}, fmt.Errorf("Invalid number of packages in aur package: %s, found %v", pkgName, result.Results)
@@ -105,10 +395,37 @@ func (client Client) getAurPackageVersions(pkgName string) (AurData, error) {
 	if result.Resultcount == 0 {
 		return AurData{new: true}, nil
 	}
-	version := result.Results[0].Version
-	index := strings.LastIndex(version, "-")
-	pkgRel, err := strconv.Atoi(version[index+1:])
+	data, err := parseAurVersion(result.Results[0].Version)
+	data.info = result.Results[0]
+	return data, err
+}
+
+// getMirrorPackageVersions reads the version from the .SRCINFO on the mirror,
+// a package without a branch there is treated as new.
+func (client Client) getMirrorPackageVersions(ctx context.Context, pkgName string) (AurData, error) {
+	body, err := client.Get(ctx, expandAurPattern(client.mirrorURL, pkgName, ".SRCINFO"))
+	var status statusError
+	if errors.As(err, &status) && status.StatusCode == http.StatusNotFound {
+		slog.Warn("Package not found on the mirror, treating it as new", "pkgname", pkgName)
+		return AurData{new: true}, nil
+	}
+	if err != nil {
+		return AurData{}, err
+	}
+	version, err := parser.ExtractSrcinfoVersion(string(body))
 	if err != nil {
+		return AurData{}, err
+	}
+	return parseAurVersion(version)
+}
+
+func parseAurVersion(version string) (AurData, error) {
+	index := strings.LastIndex(version, "-")
+	if index < 0 {
+		return AurData{}, fmt.Errorf("Couldn't parse pkgRel in version %s", version)
+	}
+	pkgRel := version[index+1:]
+	if !pkgrelPattern.MatchString(pkgRel) {
 		return AurData{}, fmt.Errorf("Couldn't parse pkgRel in version %s", version[index:])
 	}
 
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
@@ -31,7 +37,7 @@ pkgrel=1`
 		defer server.Close()
 
 		client := DummyClient(server)
-		result, err := client.fetchPKGBUILD("test-pkg")
+		result, err := client.fetchPKGBUILD(context.Background(), "test-pkg")
 
 		assert.NoError(t, err)
 		assert.Equal(t, expectedContent, result)
@@ -44,7 +50,7 @@ pkgrel=1`
 		defer server.Close()
 
 		client := DummyClient(server)
-		_, err := client.fetchPKGBUILD("nonexistent")
+		_, err := client.fetchPKGBUILD(context.Background(), "nonexistent")
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "404")
@@ -57,7 +63,7 @@ pkgrel=1`
 		defer server.Close()
 
 		client := DummyClient(server)
-		_, err := client.fetchPKGBUILD("test-pkg")
+		_, err := client.fetchPKGBUILD(context.Background(), "test-pkg")
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "500")
@@ -74,11 +80,26 @@ func TestClient_getAurPackageVersions(t *testing.T) {
 		defer server.Close()
 
 		client := DummyClient(server)
-		result, err := client.getAurPackageVersions("test-pkg")
+		result, err := client.getAurPackageVersions(context.Background(), "test-pkg")
 
 		assert.NoError(t, err)
 		assert.Equal(t, "1.2.3", result.version)
-		assert.Equal(t, 5, result.pkgrel)
+		assert.Equal(t, "5", result.pkgrel)
+	})
+
+	t.Run("escapes the name", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/rpc/?v=5&type=info&arg[]=gtk%2B-pkg", r.URL.String())
+			assert.Equal(t, []string{"gtk+-pkg"}, r.URL.Query()["arg[]"])
+			w.Write([]byte(`{"resultcount":1,"results":[{"Name":"gtk+-pkg","Version":"1.0.0-1"}]}`))
+		}))
+		defer server.Close()
+
+		client := DummyClient(server)
+		result, err := client.getAurPackageVersions(context.Background(), "gtk+-pkg")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "1.0.0", result.version)
 	})
 
 	t.Run("500 server error", func(t *testing.T) {
@@ -88,7 +109,7 @@ func TestClient_getAurPackageVersions(t *testing.T) {
 		defer server.Close()
 
 		client := DummyClient(server)
-		_, err := client.getAurPackageVersions("test-pkg")
+		_, err := client.getAurPackageVersions(context.Background(), "test-pkg")
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "500")
@@ -102,12 +123,24 @@ func TestClient_getAurPackageVersions(t *testing.T) {
 		defer server.Close()
 
 		client := DummyClient(server)
-		_, err := client.getAurPackageVersions("test-pkg")
+		_, err := client.getAurPackageVersions(context.Background(), "test-pkg")
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "unmarshal")
 	})
 
+	t.Run("version without pkgrel", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"resultcount":1,"results":[{"Name":"test-pkg","Version":"1"}]}`))
+		}))
+		defer server.Close()
+
+		client := DummyClient(server)
+		_, err := client.getAurPackageVersions(context.Background(), "test-pkg")
+
+		assert.EqualError(t, err, "Couldn't parse pkgRel in version 1")
+	})
+
 	t.Run("resultcount is 0", func(t *testing.T) {
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
@@ -116,7 +149,7 @@ func TestClient_getAurPackageVersions(t *testing.T) {
 		defer server.Close()
 
 		client := DummyClient(server)
-		data, err := client.getAurPackageVersions("nonexistent")
+		data, err := client.getAurPackageVersions(context.Background(), "nonexistent")
 		assert.NoError(t, err)
 		assert.True(t, data.new)
 	})
@@ -129,7 +162,7 @@ func TestClient_getAurPackageVersions(t *testing.T) {
 		defer server.Close()
 
 		client := DummyClient(server)
-		_, err := client.getAurPackageVersions("test")
+		_, err := client.getAurPackageVersions(context.Background(), "test")
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "Invalid number of packages")
@@ -143,20 +176,32 @@ func TestClient_getAurPackageVersions(t *testing.T) {
 		defer server.Close()
 
 		client := DummyClient(server)
-		_, err := client.getAurPackageVersions("test")
+		_, err := client.getAurPackageVersions(context.Background(), "test")
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "parse pkgRel")
 	})
+
+	t.Run("sub-release pkgrel", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"resultcount":1,"results":[{"Name":"test","Version":"1.0.0-2.1"}]}`))
+		}))
+		defer server.Close()
+
+		data, err := DummyClient(server).getAurPackageVersions(context.Background(), "test")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "2.1", data.pkgrel)
+	})
 	t.Run("http.Get error in fetchPKGBUILD", func(t *testing.T) {
 		client := Client{base: "http://invalid-url-that-does-not-exist", client: &http.Client{Timeout: 100 * time.Millisecond}, tries: 1}
-		_, err := client.fetchPKGBUILD("test")
+		_, err := client.fetchPKGBUILD(context.Background(), "test")
 
 		assert.Error(t, err)
 	})
 	t.Run("http.Get error in getAurPackageVersions", func(t *testing.T) {
 		client := Client{base: "http://invalid-url-that-does-not-exist", client: &http.Client{Timeout: 100 * time.Millisecond}, tries: 1}
-		_, err := client.getAurPackageVersions("test")
+		_, err := client.getAurPackageVersions(context.Background(), "test")
 
 		assert.Error(t, err)
 	})
@@ -176,8 +221,356 @@ func TestClient_getAurPackageVersions(t *testing.T) {
 		client.tries = 5
 		expectedTries = client.tries
 		client.waitRetryDuration = 200 * time.Millisecond
-		_, err := client.getAur("")
+		_, err := client.getAur(context.Background(), "")
 		assert.NoError(t, err)
 		assert.Equal(t, client.tries, hits)
 	})
 }
+
+type closeTrackingTransport struct {
+	open atomic.Int32
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	transport *closeTrackingTransport
+}
+
+func (b trackedBody) Close() error {
+	b.transport.open.Add(-1)
+	return b.ReadCloser.Close()
+}
+
+func (t *closeTrackingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.open.Add(1)
+	resp.Body = trackedBody{ReadCloser: resp.Body, transport: t}
+	return resp, nil
+}
+
+func TestClient_Get(t *testing.T) {
+	tests := []struct {
+		name        string
+		statuses    []int
+		retryAfter  string
+		tries       int
+		wantHits    int
+		errContains string
+	}{
+		{name: "no retry on success", statuses: []int{200}, tries: 3, wantHits: 1},
+		{name: "retries 5xx", statuses: []int{502, 503, 200}, tries: 3, wantHits: 3},
+		{name: "retries 429", statuses: []int{429, 200}, tries: 3, wantHits: 2},
+		{name: "gives up after tries", statuses: []int{500, 500, 500}, tries: 2, wantHits: 2, errContains: "500"},
+		{name: "no retry on 404", statuses: []int{404, 200}, tries: 3, wantHits: 1, errContains: "404"},
+		{name: "no retry on 403", statuses: []int{403, 200}, tries: 3, wantHits: 1, errContains: "403"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[min(int(hits.Add(1)), len(tt.statuses))-1]
+				w.WriteHeader(status)
+				w.Write([]byte("body"))
+			}))
+			defer server.Close()
+
+			transport := &closeTrackingTransport{}
+			client := DummyClient(server)
+			client.client = &http.Client{Transport: transport}
+			client.tries = tt.tries
+			client.waitRetryDuration = time.Millisecond
+
+			body, err := client.Get(context.Background(), server.URL)
+			assert.Equal(t, tt.wantHits, int(hits.Load()))
+			assert.Zero(t, transport.open.Load(), "every response body should be closed")
+			if tt.errContains != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errContains)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "body", string(body))
+		})
+	}
+
+	t.Run("honors Retry-After", func(t *testing.T) {
+		var hits atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if hits.Add(1) == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		client := DummyClient(server)
+		client.tries = 2
+		client.waitRetryDuration = time.Millisecond
+
+		start := time.Now()
+		_, err := client.Get(context.Background(), server.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, int(hits.Load()))
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	})
+
+	t.Run("caps Retry-After", func(t *testing.T) {
+		var hits atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if hits.Add(1) == 1 {
+				w.Header().Set("Retry-After", "3600")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		client := DummyClient(server)
+		client.tries = 2
+		client.waitRetryDuration = time.Millisecond
+		client.maxWaitDuration = 10 * time.Millisecond
+
+		start := time.Now()
+		_, err := client.Get(context.Background(), server.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, int(hits.Load()))
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("retries dropped connections", func(t *testing.T) {
+		var hits atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if hits.Add(1) == 1 {
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+			w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		client := DummyClient(server)
+		client.tries = 2
+		client.waitRetryDuration = time.Millisecond
+
+		body, err := client.Get(context.Background(), server.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", string(body))
+		assert.Equal(t, 2, int(hits.Load()))
+	})
+
+	t.Run("stops waiting when the context is cancelled", func(t *testing.T) {
+		var hits atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		client := DummyClient(server)
+		client.tries = 5
+
+		start := time.Now()
+		_, err := client.Get(ctx, server.URL)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 1, int(hits.Load()))
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	client := Client{waitRetryDuration: time.Second, maxWaitDuration: 10 * time.Second}
+	for attempt, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		wait := client.backoff(attempt + 1)
+		assert.GreaterOrEqual(t, wait, expected/2)
+		assert.LessOrEqual(t, wait, expected)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
+
+func TestIsRetryableError(t *testing.T) {
+	assert.True(t, isRetryableError(&net.DNSError{Err: "server misbehaving", Name: "aur.archlinux.org", IsTemporary: true}))
+	assert.True(t, isRetryableError(&net.DNSError{Err: "i/o timeout", Name: "aur.archlinux.org", IsTimeout: true}))
+	assert.True(t, isRetryableError(&net.OpError{Op: "read", Err: syscall.ECONNRESET}))
+	assert.True(t, isRetryableError(io.ErrUnexpectedEOF))
+	assert.False(t, isRetryableError(&net.DNSError{Err: "no such host", Name: "aur.invalid", IsNotFound: true}))
+	assert.False(t, isRetryableError(&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}))
+	assert.False(t, isRetryableError(errors.New("unsupported protocol scheme")))
+}
+
+func TestClient_WithAur(t *testing.T) {
+	t.Run("custom endpoint", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.String() {
+			case "/api/rpc?v=6&type=info&arg[]=test-pkg":
+				w.Write([]byte(`{"resultcount":1,"results":[{"Name":"test-pkg","Version":"1.0.0-3"}]}`))
+			case "/cgit/test-pkg.git/plain/PKGBUILD":
+				w.Write([]byte("pkgname=test-pkg"))
+			default:
+				t.Errorf("unexpected request %s", r.URL)
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		client := DummyClient(server).WithAur(server.URL+"/", "/api/rpc", "6", "/cgit/{pkgname}.git/plain/{file}", "")
+		data, err := client.getAurPackageVersions(context.Background(), "test-pkg")
+		assert.NoError(t, err)
+		assert.Equal(t, "1.0.0", data.version)
+		assert.Equal(t, "3", data.pkgrel)
+		pkgbuild, err := client.fetchPKGBUILD(context.Background(), "test-pkg")
+		assert.NoError(t, err)
+		assert.Equal(t, "pkgname=test-pkg", pkgbuild)
+	})
+
+	t.Run("absolute raw file pattern", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/raw/test-pkg/PKGBUILD", r.URL.Path)
+			w.Write([]byte("pkgname=test-pkg"))
+		}))
+		defer server.Close()
+
+		client := DummyClient(server).WithAur("https://aur.invalid", "", "", server.URL+"/raw/{pkgname}/{file}", "")
+		pkgbuild, err := client.fetchPKGBUILD(context.Background(), "test-pkg")
+		assert.NoError(t, err)
+		assert.Equal(t, "pkgname=test-pkg", pkgbuild)
+	})
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/test-pkg/PKGBUILD":
+			w.Write([]byte("pkgname=test-pkg"))
+		case "/test-pkg/.SRCINFO":
+			w.Write([]byte("pkgbase = test-pkg\n\tpkgver = 2.0.0\n\tpkgrel = 4\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mirror.Close()
+	mirrorURL := mirror.URL + "/{pkgname}/{file}"
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	t.Run("mirror fallback for PKGBUILD", func(t *testing.T) {
+		client := DummyClient(down).WithAur(down.URL, "", "", "", mirrorURL)
+		pkgbuild, err := client.fetchPKGBUILD(context.Background(), "test-pkg")
+		assert.NoError(t, err)
+		assert.Equal(t, "pkgname=test-pkg", pkgbuild)
+	})
+
+	t.Run("mirror fallback for versions", func(t *testing.T) {
+		client := DummyClient(down).WithAur(down.URL, "", "", "", mirrorURL)
+		data, err := client.getAurPackageVersions(context.Background(), "test-pkg")
+		assert.NoError(t, err)
+		assert.Equal(t, AurData{version: "2.0.0", pkgrel: "4"}, data)
+	})
+
+	t.Run("package missing on mirror is new", func(t *testing.T) {
+		client := DummyClient(down).WithAur(down.URL, "", "", "", mirrorURL)
+		data, err := client.getAurPackageVersions(context.Background(), "other-pkg")
+		assert.NoError(t, err)
+		assert.True(t, data.new)
+	})
+
+	t.Run("no fallback without mirror", func(t *testing.T) {
+		client := DummyClient(down).WithAur(down.URL, "", "", "", "")
+		_, err := client.fetchPKGBUILD(context.Background(), "test-pkg")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "503")
+	})
+
+	t.Run("no fallback when the AUR answers", func(t *testing.T) {
+		notFound := httptest.NewServer(http.NotFoundHandler())
+		defer notFound.Close()
+
+		client := DummyClient(notFound).WithAur(notFound.URL, "", "", "", mirrorURL)
+		_, err := client.fetchPKGBUILD(context.Background(), "test-pkg")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "404")
+	})
+}
+
+func TestClient_getAurPackageInfo(t *testing.T) {
+	t.Run("full info", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"version":5,"type":"multiinfo","resultcount":1,"results":[{
+				"ID":1,"Name":"test-pkg","PackageBaseID":2,"PackageBase":"test-pkg","Version":"1:1.2.3-5",
+				"Description":"test","URL":"https://example.com","NumVotes":3,"Popularity":0.5,
+				"OutOfDate":1735732800,"Maintainer":"someone","CoMaintainers":["helper"],"Submitter":"someone",
+				"FirstSubmitted":1700000000,"LastModified":1735000000,"URLPath":"/cgit/aur.git/snapshot/test-pkg.tar.gz",
+				"Depends":["glibc"],"MakeDepends":["go"],"License":["MIT"],"Keywords":["cli","tool"]}]}`))
+		}))
+		defer server.Close()
+
+		data, err := DummyClient(server).getAurPackageVersions(context.Background(), "test-pkg")
+		assert.NoError(t, err)
+		assert.Equal(t, "1:1.2.3", data.version)
+		assert.Equal(t, "5", data.pkgrel)
+		assert.Equal(t, AurPackage{
+			ID:             1,
+			Name:           "test-pkg",
+			PackageBaseID:  2,
+			PackageBase:    "test-pkg",
+			Version:        "1:1.2.3-5",
+			Description:    "test",
+			URL:            "https://example.com",
+			NumVotes:       3,
+			Popularity:     0.5,
+			OutOfDate:      1735732800,
+			Maintainer:     "someone",
+			CoMaintainers:  []string{"helper"},
+			Submitter:      "someone",
+			FirstSubmitted: 1700000000,
+			LastModified:   1735000000,
+			URLPath:        "/cgit/aur.git/snapshot/test-pkg.tar.gz",
+			Depends:        []string{"glibc"},
+			MakeDepends:    []string{"go"},
+			License:        []string{"MIT"},
+			Keywords:       []string{"cli", "tool"},
+		}, data.info)
+	})
+
+	t.Run("orphaned package", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"resultcount":1,"results":[{"Name":"test-pkg","Version":"1.0.0-1","Maintainer":null,"OutOfDate":null}]}`))
+		}))
+		defer server.Close()
+
+		data, err := DummyClient(server).getAurPackageVersions(context.Background(), "test-pkg")
+		assert.NoError(t, err)
+		assert.Empty(t, data.info.Maintainer)
+		assert.Zero(t, data.info.OutOfDate)
+	})
+
+	t.Run("rpc error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"version":5,"type":"error","resultcount":0,"results":[],"error":"Incorrect request type specified."}`))
+		}))
+		defer server.Close()
+
+		_, err := DummyClient(server).getAurPackageVersions(context.Background(), "test-pkg")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "Incorrect request type specified.")
+	})
+}
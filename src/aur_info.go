@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxRPCURLLength keeps batched info requests below the URL length web
+// servers commonly accept, aurweb answers longer ones with 414.
+const maxRPCURLLength = 4000
+
+// aurRequestInterval spaces out requests to the AUR, which limits every
+// address to a few thousand requests a day.
+const aurRequestInterval = 250 * time.Millisecond
+
+// rateLimiter lets one request through every interval, a nil rateLimiter never waits.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(interval time.Duration) *rateLimiter {
+	return &rateLimiter{interval: interval}
+}
+
+func (limiter *rateLimiter) wait(ctx context.Context) error {
+	if limiter == nil {
+		return nil
+	}
+	limiter.mu.Lock()
+	now := time.Now()
+	delay := max(0, limiter.next.Sub(now))
+	limiter.next = now.Add(delay + limiter.interval)
+	limiter.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// aurInfoCache holds the AurData of packages looked up in a batch, so the
+// comparator of every package does not query the RPC again.
+type aurInfoCache struct {
+	mu   sync.Mutex
+	data map[string]AurData
+}
+
+func (cache *aurInfoCache) get(pkgName string) (AurData, bool) {
+	if cache == nil {
+		return AurData{}, false
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	data, ok := cache.data[pkgName]
+	return data, ok
+}
+
+func (cache *aurInfoCache) set(pkgName string, data AurData) {
+	if cache == nil {
+		return
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.data[pkgName] = data
+}
+
+// chunkRPCArgs splits pkgNames into groups whose info request stays below maxRPCURLLength.
+func chunkRPCArgs(prefix string, pkgNames []string) [][]string {
+	var chunks [][]string
+	var chunk []string
+	length := len(prefix)
+	for _, name := range pkgNames {
+		arg := len("&arg[]=") + len(url.QueryEscape(name))
+		if len(chunk) > 0 && length+arg > maxRPCURLLength {
+			chunks = append(chunks, chunk)
+			chunk, length = nil, len(prefix)
+		}
+		chunk = append(chunk, name)
+		length += arg
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// getAurPackagesVersions looks up all packages with as few info requests as the
+// URL length allows. Packages missing from the AUR are returned as new.
+func (client Client) getAurPackagesVersions(ctx context.Context, pkgNames []string) (map[string]AurData, error) {
+	pkgNames = slices.Compact(slices.Sorted(slices.Values(pkgNames)))
+	prefix := client.base + client.rpcInfoPath()
+
+	result := make(map[string]AurData, len(pkgNames))
+	for _, chunk := range chunkRPCArgs(prefix, pkgNames) {
+		path := client.rpcInfoPath()
+		for _, name := range chunk {
+			path += "&arg[]=" + url.QueryEscape(name)
+		}
+		body, err := client.getAur(ctx, path)
+		if err != nil {
+			if client.mirrorURL != "" && isUnavailable(err) {
+				slog.Warn("AUR RPC is unavailable, reading the versions from the mirror", "err", err)
+				return client.getMirrorPackagesVersions(ctx, pkgNames)
+			}
+			return nil, err
+		}
+		response, err := decodeAurResponse(body)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range response.Results {
+			data, err := parseAurVersion(info.Version)
+			if err != nil {
+				return nil, err
+			}
+			data.info = info
+			result[info.Name] = data
+		}
+	}
+
+	for _, name := range pkgNames {
+		if _, ok := result[name]; !ok {
+			result[name] = AurData{new: true}
+		}
+		client.aurInfo.set(name, result[name])
+	}
+	return result, nil
+}
+
+func (client Client) getMirrorPackagesVersions(ctx context.Context, pkgNames []string) (map[string]AurData, error) {
+	result := make(map[string]AurData, len(pkgNames))
+	for _, name := range pkgNames {
+		data, err := client.getMirrorPackageVersions(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = data
+		client.aurInfo.set(name, data)
+	}
+	return result, nil
+}
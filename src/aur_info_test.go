@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkRPCArgs(t *testing.T) {
+	names := make([]string, 300)
+	for i := range names {
+		names[i] = fmt.Sprintf("package-number-%03d-bin", i)
+	}
+	prefix := "https://aur.archlinux.org/rpc/?v=5&type=info"
+
+	chunks := chunkRPCArgs(prefix, names)
+	assert.Greater(t, len(chunks), 1)
+	var joined []string
+	for _, chunk := range chunks {
+		length := len(prefix)
+		for _, name := range chunk {
+			length += len("&arg[]=") + len(name)
+		}
+		assert.LessOrEqual(t, length, maxRPCURLLength)
+		joined = append(joined, chunk...)
+	}
+	assert.Equal(t, names, joined)
+
+	assert.Equal(t, [][]string{{"a", "b"}}, chunkRPCArgs(prefix, []string{"a", "b"}))
+	assert.Nil(t, chunkRPCArgs(prefix, nil))
+}
+
+func TestClient_getAurPackagesVersions(t *testing.T) {
+	t.Run("batched lookup", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			assert.Equal(t, "info", r.URL.Query().Get("type"))
+			var results []string
+			for _, name := range r.URL.Query()["arg[]"] {
+				if name != "new-pkg" {
+					results = append(results, fmt.Sprintf(`{"Name":%q,"Version":"1.0.0-2","Maintainer":"fuad-daoud"}`, name))
+				}
+			}
+			fmt.Fprintf(w, `{"resultcount":%d,"results":[%s]}`, len(results), strings.Join(results, ","))
+		}))
+		defer server.Close()
+
+		client := DummyClient(server)
+		client.aurInfo = &aurInfoCache{data: map[string]AurData{}}
+		data, err := client.getAurPackagesVersions(context.Background(), []string{"first-bin", "new-pkg", "gtk+", "first-bin"})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), requests.Load())
+		assert.Len(t, data, 3)
+		assert.Equal(t, "1.0.0", data["first-bin"].version)
+		assert.Equal(t, "2", data["first-bin"].pkgrel)
+		assert.Equal(t, "fuad-daoud", data["first-bin"].info.Maintainer)
+		assert.Equal(t, "gtk+", data["gtk+"].info.Name)
+		assert.True(t, data["new-pkg"].new)
+
+		cached, err := client.getAurPackageVersions(context.Background(), "first-bin")
+		assert.NoError(t, err)
+		assert.Equal(t, data["first-bin"], cached)
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("chunked lookup", func(t *testing.T) {
+		var requests atomic.Int32
+		var server *httptest.Server
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			assert.LessOrEqual(t, len(server.URL)+len(r.URL.RequestURI()), maxRPCURLLength)
+			w.Write([]byte(`{"resultcount":0,"results":[]}`))
+		}))
+		defer server.Close()
+
+		names := make([]string, 400)
+		for i := range names {
+			names[i] = fmt.Sprintf("package-number-%03d-bin", i)
+		}
+		data, err := DummyClient(server).getAurPackagesVersions(context.Background(), names)
+		assert.NoError(t, err)
+		assert.Len(t, data, 400)
+		assert.Greater(t, requests.Load(), int32(1))
+	})
+
+	t.Run("rpc error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"type":"error","error":"Too many package results."}`))
+		}))
+		defer server.Close()
+
+		_, err := DummyClient(server).getAurPackagesVersions(context.Background(), []string{"a"})
+		assert.ErrorContains(t, err, "Too many package results.")
+	})
+
+	t.Run("mirror fallback", func(t *testing.T) {
+		down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer down.Close()
+		mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/first-bin/.SRCINFO" {
+				w.Write([]byte("pkgbase = first-bin\n\tpkgver = 3.0.0\n\tpkgrel = 1\n"))
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer mirror.Close()
+
+		client := DummyClient(down).WithAur(down.URL, "", "", "", mirror.URL+"/{pkgname}/{file}")
+		data, err := client.getAurPackagesVersions(context.Background(), []string{"first-bin", "second-bin"})
+		assert.NoError(t, err)
+		assert.Equal(t, "3.0.0", data["first-bin"].version)
+		assert.True(t, data["second-bin"].new)
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(20 * time.Millisecond)
+	start := time.Now()
+	for range 4 {
+		assert.NoError(t, limiter.wait(context.Background()))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	slow := newRateLimiter(time.Hour)
+	assert.NoError(t, slow.wait(ctx))
+	assert.ErrorIs(t, slow.wait(ctx), context.Canceled)
+
+	var disabled *rateLimiter
+	assert.NoError(t, disabled.wait(context.Background()))
+}
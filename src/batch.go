@@ -0,0 +1,207 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// batchManifest lists several packages to generate in one run. Every key is the
+// name of the environment variable it stands for, values can be strings,
+// booleans, numbers or lists. A package entry overrides the defaults, which
+// override the environment.
+type batchManifest struct {
+	Defaults map[string]any   `json:"defaults"`
+	Packages []map[string]any `json:"packages"`
+}
+
+type batchResult struct {
+	Pkgname string
+	Version string
+	Pkgrel  string
+	Err     error
+	Report  *runReport
+}
+
+func loadBatchManifest(path string) (batchManifest, error) {
+	var manifest batchManifest
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to read batch manifest: %w", err)
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return manifest, fmt.Errorf("Could not unmarshal the batch manifest: %v", err)
+	}
+	if len(manifest.Packages) == 0 {
+		return manifest, fmt.Errorf("batch manifest %s has no packages", path)
+	}
+	return manifest, nil
+}
+
+// configValue turns a manifest value into the string form of its environment variable.
+func configValue(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case bool:
+		return strconv.FormatBool(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case []any:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = configValue(item)
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func (manifest batchManifest) defaults(env config) config {
+	return func(key string) string {
+		if value, ok := manifest.Defaults[key]; ok {
+			return configValue(value)
+		}
+		return env(key)
+	}
+}
+
+// packageConfig is the config of the i-th package. Unless a package sets its
+// own output_path it is written to a directory named after it, so packages
+// sharing the default output path do not overwrite each other.
+func (manifest batchManifest) packageConfig(i int, env config) config {
+	defaults := manifest.defaults(env)
+	var lookup config
+	lookup = func(key string) string {
+		if value, ok := manifest.Packages[i][key]; ok {
+			return configValue(value)
+		}
+		switch key {
+		case "output_path":
+			return filepath.Join(defaults.get("output_path", "./output/"), lookup("pkgname")) + "/"
+		}
+		return defaults(key)
+	}
+	return lookup
+}
+
+// extra are the extra variables of the i-th package, from its own entry, the
+// defaults and the environment.
+func (manifest batchManifest) extra(i int, env config) map[string]string {
+	keys := slices.Concat(slices.Collect(maps.Keys(manifest.Packages[i])), slices.Collect(maps.Keys(manifest.Defaults)), environKeys())
+	return extraVariables(keys, manifest.packageConfig(i, env))
+}
+
+// runBatch generates every package of the manifest, up to concurrency at a time,
+// all sharing client. The AUR versions of all packages are looked up upfront in
+// batched requests. A failing package does not stop the others.
+func runBatch(ctx context.Context, client Client, manifest batchManifest, env config, concurrency int) []batchResult {
+	pkgbuilds := make([]*PkgBuild, len(manifest.Packages))
+	var pkgNames []string
+	for i := range manifest.Packages {
+		pkgbuilds[i] = newPkgBuildFromConfig(manifest.packageConfig(i, env))
+		// a report is part of the batch report unless the package sets its own report_path
+		pkgbuilds[i].reportPath = configValue(manifest.Packages[i]["report_path"])
+		pkgbuilds[i].Extra = manifest.extra(i, env)
+		if pkgbuilds[i].Pkgname != "" {
+			pkgNames = append(pkgNames, pkgbuilds[i].Pkgname)
+		}
+	}
+	if _, err := client.getAurPackagesVersions(ctx, pkgNames); err != nil {
+		slog.Warn("Failed to look up the packages on the AUR, looking them up one by one", "err", err)
+	}
+
+	results := make([]batchResult, len(pkgbuilds))
+	limit := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i, pkgbuild := range pkgbuilds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			results[i] = batchResult{Pkgname: pkgbuild.Pkgname, Version: pkgbuild.Version}
+			if err := validate(*pkgbuild); err != nil {
+				slog.Error("Validation failed", "pkgname", pkgbuild.Pkgname, "err", err)
+				results[i].Err = err
+				return
+			}
+			if _, err := pkgbuild.generateWith(ctx, client); err != nil {
+				slog.Error("Generation failed", "pkgname", pkgbuild.Pkgname, "err", err)
+				results[i].Err = err
+				return
+			}
+			results[i].Pkgrel = pkgbuild.Pkgrel
+			results[i].Report = &pkgbuild.report
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// writeBatchSummary prints one line per package and returns how many failed.
+func writeBatchSummary(w io.Writer, results []batchResult) int {
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	fmt.Fprintf(w, "Generated %d of %d packages\n", len(results)-failed, len(results))
+	for i, result := range results {
+		name := cmp.Or(result.Pkgname, fmt.Sprintf("package #%d", i+1))
+		if result.Err != nil {
+			fmt.Fprintf(w, "  FAIL %s: %v\n", name, result.Err)
+			continue
+		}
+		if result.Report != nil && result.Report.UpToDate {
+			fmt.Fprintf(w, "  OK   %s %s-%s already published\n", name, result.Version, result.Pkgrel)
+			continue
+		}
+		fmt.Fprintf(w, "  OK   %s %s-%s\n", name, result.Version, result.Pkgrel)
+	}
+	return failed
+}
+
+func generateBatch(ctx context.Context, path string, env config) error {
+	manifest, err := loadBatchManifest(path)
+	if err != nil {
+		return err
+	}
+	concurrency, err := strconv.Atoi(env.get("batch_concurrency", "4"))
+	if err != nil {
+		return fmt.Errorf("invalid batch concurrency: %w", err)
+	}
+
+	client := newPkgBuildFromConfig(manifest.defaults(env)).newClient()
+	results := runBatch(ctx, client, manifest, env, concurrency)
+	reportPath := manifest.defaults(env).get("report_path", "release-aur-report.json")
+	if err := writeReport(reportPath, newBatchReport(results)); err != nil {
+		return err
+	}
+	detectGitHubActions(env).reportBatch(results, reportPath)
+	if failed := writeBatchSummary(os.Stdout, results); failed != 0 {
+		batchErr := &batchError{total: len(results)}
+		for _, result := range results {
+			if result.Err != nil {
+				batchErr.errs = append(batchErr.errs, result.Err)
+			}
+		}
+		return batchErr
+	}
+	return nil
+}
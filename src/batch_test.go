@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigValue(t *testing.T) {
+	tests := []struct {
+		value    any
+		expected string
+	}{
+		{value: nil, expected: ""},
+		{value: "MIT", expected: "MIT"},
+		{value: true, expected: "true"},
+		{value: float64(2), expected: "2"},
+		{value: []any{"x86_64", "aarch64"}, expected: "x86_64,aarch64"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, configValue(tt.value))
+	}
+}
+
+func TestBatchManifest_packageConfig(t *testing.T) {
+	manifest := batchManifest{
+		Defaults: map[string]any{"licence": "MIT", "arch": []any{"x86_64", "aarch64"}},
+		Packages: []map[string]any{
+			{"pkgname": "first-bin", "licence": "Apache-2.0"},
+			{"pkgname": "second-bin", "output_path": "custom/"},
+		},
+	}
+	env := config(func(key string) string {
+		return map[string]string{"licence": "GPL-3.0-only", "maintainers": "Fuad Daoud <aur@fuad-daoud.com>", "output_path": "out/"}[key]
+	})
+
+	first := manifest.packageConfig(0, env)
+	assert.Equal(t, "Apache-2.0", first("licence"))
+	assert.Equal(t, "x86_64,aarch64", first("arch"))
+	assert.Equal(t, "Fuad Daoud <aur@fuad-daoud.com>", first("maintainers"))
+	assert.Equal(t, "out/first-bin/", first("output_path"))
+
+	second := manifest.packageConfig(1, env)
+	assert.Equal(t, "MIT", second("licence"))
+	assert.Equal(t, "custom/", second("output_path"))
+}
+
+func TestBatchManifest_extra(t *testing.T) {
+	t.Setenv("extra_from_env", "env")
+	manifest := batchManifest{
+		Defaults: map[string]any{"extra_shell": "bash", "extra_docs": "README.md"},
+		Packages: []map[string]any{
+			{"pkgname": "first-bin", "extra_shell": "zsh", "extra_completions": []any{"a", "b"}},
+		},
+	}
+
+	assert.Equal(t, map[string]string{"Shell": "zsh", "Docs": "README.md", "Completions": "a,b", "FromEnv": "env"}, manifest.extra(0, os.Getenv))
+}
+
+func TestLoadBatchManifest(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	manifest, err := loadBatchManifest(write("ok.json", `{"defaults":{"licence":"MIT"},"packages":[{"pkgname":"a"}]}`))
+	assert.NoError(t, err)
+	assert.Len(t, manifest.Packages, 1)
+
+	_, err = loadBatchManifest(write("empty.json", `{"defaults":{"licence":"MIT"}}`))
+	assert.ErrorContains(t, err, "has no packages")
+
+	_, err = loadBatchManifest(write("invalid.json", `{`))
+	assert.ErrorContains(t, err, "unmarshal the batch manifest")
+
+	_, err = loadBatchManifest(filepath.Join(dir, "missing.json"))
+	assert.ErrorContains(t, err, "failed to read batch manifest")
+}
+
+func TestRunBatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rpc/":
+			w.Write([]byte(`{"resultcount":0,"results":[]}`))
+		case "/first", "/third":
+			w.Write([]byte("binary"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	output := t.TempDir()
+	manifest := batchManifest{
+		Defaults: map[string]any{
+			"maintainers": []any{"Fuad Daoud <aur@fuad-daoud.com>"},
+			"licence":     "MIT",
+			"arch":        "x86_64",
+			"url":         "https://github.com/fuad-daoud/release-aur",
+			"version":     "1.0.0",
+			"aur_url":     server.URL,
+			"output_path": output,
+		},
+		Packages: []map[string]any{
+			{"pkgname": "first-bin", "cli_name": "first", "description": "first", "source_x86_64": server.URL + "/first", "report_path": filepath.Join(output, "first-bin.json")},
+			{"pkgname": "second-bin", "cli_name": "second", "description": "second", "source_x86_64": server.URL + "/second"},
+			{"pkgname": "third-bin", "cli_name": "third", "source_x86_64": server.URL + "/third"},
+			{"pkgname": "fourth-bin", "cli_name": "fourth", "description": "fourth", "source_x86_64": server.URL + "/third", "version": "2.0.0"},
+		},
+	}
+	env := config(func(key string) string { return "" })
+
+	client := DummyClient(server)
+	results := runBatch(context.Background(), client, manifest, env, 2)
+
+	assert.Len(t, results, 4)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "1", results[0].Pkgrel)
+	assert.Equal(t, decisionNewPackage, results[0].Report.Decision)
+	assert.ErrorContains(t, results[1].Err, "404")
+	assert.ErrorContains(t, results[2].Err, "Description is required")
+	assert.NoError(t, results[3].Err)
+	assert.Equal(t, "2.0.0", results[3].Version)
+
+	assert.FileExists(t, filepath.Join(output, "first-bin", "PKGBUILD"))
+	assert.FileExists(t, filepath.Join(output, "first-bin", ".SRCINFO"))
+	assert.FileExists(t, filepath.Join(output, "fourth-bin", "PKGBUILD"))
+	assert.NoDirExists(t, filepath.Join(output, "second-bin"))
+	assert.FileExists(t, filepath.Join(output, "first-bin.json"))
+	assert.NoFileExists(t, "release-aur-report.json")
+}
+
+func TestWriteBatchSummary(t *testing.T) {
+	var summary bytes.Buffer
+	failed := writeBatchSummary(&summary, []batchResult{
+		{Pkgname: "first-bin", Version: "1.0.0", Pkgrel: "2"},
+		{Pkgname: "second-bin", Version: "1.0.0", Err: errors.New("boom")},
+		{Err: errors.New("Pkgname is required")},
+	})
+
+	assert.Equal(t, 2, failed)
+	assert.Equal(t, `Generated 1 of 3 packages
+  OK   first-bin 1.0.0-2
+  FAIL second-bin: boom
+  FAIL package #3: Pkgname is required
+`, summary.String())
+}
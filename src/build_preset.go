@@ -0,0 +1,43 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// buildPresets maps a source-mode BuildPreset to the makedepends its
+// prepare(), build(), check() and package() functions need.
+// The functions themselves live in the templates/source-<preset>.tmpl templates.
+var buildPresets = map[string][]string{
+	"go":   {"go"},
+	"rust": {"cargo"},
+	"make": {},
+}
+
+func buildPresetNames() string {
+	names := make([]string, 0, len(buildPresets))
+	for name := range buildPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func (pkgbuild *PkgBuild) applyBuildPreset() error {
+	makedepends, ok := buildPresets[pkgbuild.BuildPreset]
+	if !ok {
+		return invalid("BuildPreset", "Unknown BuildPreset %q, expected one of: %s", pkgbuild.BuildPreset, buildPresetNames())
+	}
+
+	for _, dep := range makedepends {
+		if !slices.Contains(pkgbuild.Makedepends, dep) {
+			pkgbuild.Makedepends = append(pkgbuild.Makedepends, dep)
+		}
+	}
+
+	if pkgbuild.SourceDir == "" {
+		pkgbuild.SourceDir = "$pkgname-$pkgver"
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyBuildPreset(t *testing.T) {
+	tests := []struct {
+		name                string
+		pkg                 PkgBuild
+		expectedMakedepends []string
+		expectedSourceDir   string
+		wantErr             bool
+	}{
+		{
+			name:                "go preset adds go",
+			pkg:                 PkgBuild{BuildPreset: "go"},
+			expectedMakedepends: []string{"go"},
+			expectedSourceDir:   "$pkgname-$pkgver",
+		},
+		{
+			name:                "rust preset keeps user makedepends",
+			pkg:                 PkgBuild{BuildPreset: "rust", Makedepends: []string{"clang"}},
+			expectedMakedepends: []string{"clang", "cargo"},
+			expectedSourceDir:   "$pkgname-$pkgver",
+		},
+		{
+			name:                "no duplicated makedepends",
+			pkg:                 PkgBuild{BuildPreset: "go", Makedepends: []string{"go"}},
+			expectedMakedepends: []string{"go"},
+			expectedSourceDir:   "$pkgname-$pkgver",
+		},
+		{
+			name:                "make preset with custom source dir",
+			pkg:                 PkgBuild{BuildPreset: "make", SourceDir: "project-1.0.0"},
+			expectedMakedepends: nil,
+			expectedSourceDir:   "project-1.0.0",
+		},
+		{
+			name:    "unknown preset",
+			pkg:     PkgBuild{BuildPreset: "zig"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.pkg.applyBuildPreset()
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "Unknown BuildPreset")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedMakedepends, tt.pkg.Makedepends)
+			assert.Equal(t, tt.expectedSourceDir, tt.pkg.SourceDir)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/fuad-daoud/release-aur/src/parser"
+)
+
+// addLocalSources appends the local sources to Source by their filename, with
+// the checksums of the files on disk. They are added after the downloaded
+// sources were checksummed, so they always come last.
+func (pkgbuild *PkgBuild) addLocalSources() error {
+	for _, path := range pkgbuild.localSources {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to read local source: %w", err)
+		}
+		checksum, err := parser.CalculateSHA256(file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+		pkgbuild.Source = append(pkgbuild.Source, filepath.Base(path))
+		pkgbuild.Checksum = append(pkgbuild.Checksum, checksum)
+		slog.Info("Calculated checksum", "source", path, "sha256", checksum)
+	}
+	return nil
+}
+
+// splitLocalChecksums splits Checksum into the checksums of the downloaded
+// sources and of the local sources.
+func (pkgbuild PkgBuild) splitLocalChecksums() ([]string, []string) {
+	downloaded := max(len(pkgbuild.Checksum)-len(pkgbuild.localSources), 0)
+	return pkgbuild.Checksum[:downloaded], pkgbuild.Checksum[downloaded:]
+}
+
+// readChangelog reads the changelog shipped next to the PKGBUILD.
+func (pkgbuild *PkgBuild) readChangelog() error {
+	if pkgbuild.changelogPath == "" {
+		return nil
+	}
+	content, err := os.ReadFile(pkgbuild.changelogPath)
+	if err != nil {
+		return fmt.Errorf("failed to read changelog: %w", err)
+	}
+	pkgbuild.changelog = string(content)
+	return nil
+}
+
+// writeCompanionFiles writes the install file, the changelog and the local
+// sources next to the PKGBUILD and adds them to files.
+func (pkgbuild PkgBuild) writeCompanionFiles(files *reportFiles) error {
+	if pkgbuild.Install != "" {
+		files.Install = pkgbuild.outputPath + pkgbuild.Install
+		if err := writeFile(files.Install, pkgbuild.installScript); err != nil {
+			return err
+		}
+		slog.Info("Wrote install file", "file", pkgbuild.Install)
+	}
+	if pkgbuild.Changelog != "" {
+		files.Changelog = pkgbuild.outputPath + pkgbuild.Changelog
+		if err := writeFile(files.Changelog, pkgbuild.changelog); err != nil {
+			return err
+		}
+		slog.Info("Wrote changelog", "file", pkgbuild.Changelog)
+	}
+	var err error
+	files.LocalSources, err = pkgbuild.copyLocalSources()
+	return err
+}
+
+// copyLocalSources copies the local sources next to the PKGBUILD and returns
+// their paths.
+func (pkgbuild PkgBuild) copyLocalSources() ([]string, error) {
+	paths := []string{}
+	for _, path := range pkgbuild.localSources {
+		target := pkgbuild.outputPath + filepath.Base(path)
+		if err := copyFile(path, target); err != nil {
+			return nil, fmt.Errorf("failed to copy local source %s: %w", path, err)
+		}
+		paths = append(paths, target)
+	}
+	return paths, nil
+}
+
+func copyFile(source, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
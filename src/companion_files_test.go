@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteCompanionFiles(t *testing.T) {
+	dir := t.TempDir()
+	changelog := filepath.Join(dir, "CHANGELOG.md")
+	assert.NoError(t, os.WriteFile(changelog, []byte("# 1.0.0\n"), 0644))
+
+	pkg := &PkgBuild{
+		Install:       "test.install",
+		installScript: "post_install() {\n    echo installed\n}\n",
+		Changelog:     "CHANGELOG.md",
+		changelogPath: changelog,
+		outputPath:    filepath.Join(dir, "output") + "/",
+	}
+	assert.NoError(t, pkg.readChangelog())
+	assert.Equal(t, "# 1.0.0\n", pkg.changelog)
+
+	var files reportFiles
+	assert.NoError(t, pkg.writeCompanionFiles(&files))
+	assert.Equal(t, reportFiles{
+		Install:      filepath.Join(dir, "output", "test.install"),
+		Changelog:    filepath.Join(dir, "output", "CHANGELOG.md"),
+		LocalSources: []string{},
+	}, files)
+	assert.Equal(t, "# 1.0.0\n", readFile(t, files.Changelog))
+	assert.Equal(t, pkg.installScript, readFile(t, files.Install))
+
+	missing := &PkgBuild{changelogPath: filepath.Join(dir, "missing.md")}
+	assert.ErrorContains(t, missing.readChangelog(), "failed to read changelog")
+}
+
+func TestLocalSources(t *testing.T) {
+	dir := t.TempDir()
+	service := filepath.Join(dir, "packaging", "test.service")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(service), 0755))
+	assert.NoError(t, os.WriteFile(service, []byte("[Service]\n"), 0644))
+
+	pkg := &PkgBuild{
+		Source:       []string{"LICENSE::https://example.com/LICENSE"},
+		Checksum:     []string{"license"},
+		localSources: []string{service},
+		outputPath:   filepath.Join(dir, "output") + "/",
+	}
+
+	assert.NoError(t, pkg.addLocalSources())
+	assert.Equal(t, []string{"LICENSE::https://example.com/LICENSE", "test.service"}, pkg.Source)
+	assert.Equal(t, []string{"license", "40d8baaabac85ad8ac5a2ec40dd065b4ea0fb47c25ce3ddad4c290d22503c90f"}, pkg.Checksum)
+
+	downloaded, local := pkg.splitLocalChecksums()
+	assert.Equal(t, []string{"license"}, downloaded)
+	assert.Equal(t, []string{"40d8baaabac85ad8ac5a2ec40dd065b4ea0fb47c25ce3ddad4c290d22503c90f"}, local)
+
+	paths, err := pkg.copyLocalSources()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "output", "test.service")}, paths)
+	assert.Equal(t, "[Service]\n", readFile(t, paths[0]))
+
+	missing := &PkgBuild{localSources: []string{filepath.Join(dir, "missing.service")}}
+	assert.ErrorContains(t, missing.addLocalSources(), "failed to read local source")
+}
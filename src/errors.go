@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fuad-daoud/release-aur/src/parser"
+)
+
+var (
+	// ErrAlreadyPublished means the AUR already has the same PKGBUILD for this version.
+	ErrAlreadyPublished = errors.New("PKGBUILD already published to AUR")
+	// ErrChecksumMismatch means a source does not match a published or previously released checksum.
+	ErrChecksumMismatch = parser.ErrChecksumMismatch
+	// ErrValidation is matched by every ValidationError.
+	ErrValidation = errors.New("validation failed")
+	// ErrRemoteUnavailable means a server could not be reached or kept failing after every retry.
+	ErrRemoteUnavailable = errors.New("remote unavailable")
+	// ErrDowngrade means the version is older than the one published on the AUR.
+	ErrDowngrade = errors.New("version is older than the AUR version")
+	// ErrNotMaintainer means the package on the AUR is not maintained by the configured accounts.
+	ErrNotMaintainer = errors.New("package not maintained by the configured AUR accounts")
+)
+
+// ValidationError is an invalid or missing setting, Field names the PkgBuild field.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (err *ValidationError) Error() string {
+	return err.Message
+}
+
+func (err *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+func invalid(field, format string, args ...any) error {
+	return &ValidationError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// Exit codes of the action, every one of them is documented in the README.
+const (
+	exitOK                = 0
+	exitFailure           = 1
+	exitValidation        = 2
+	exitAlreadyPublished  = 3
+	exitChecksumMismatch  = 4
+	exitDowngrade         = 5
+	exitRemoteUnavailable = 6
+	exitNotMaintainer     = 7
+)
+
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{ErrValidation, exitValidation},
+	{ErrAlreadyPublished, exitAlreadyPublished},
+	{ErrChecksumMismatch, exitChecksumMismatch},
+	{ErrDowngrade, exitDowngrade},
+	{ErrRemoteUnavailable, exitRemoteUnavailable},
+	{ErrNotMaintainer, exitNotMaintainer},
+}
+
+// batchError holds the errors of the failed packages of a batch.
+type batchError struct {
+	total int
+	errs  []error
+}
+
+func (err *batchError) Error() string {
+	return fmt.Sprintf("%d of %d packages failed", len(err.errs), err.total)
+}
+
+func (err *batchError) Unwrap() []error {
+	return err.errs
+}
+
+// exitCode maps err to the exit code of its kind. A batch exits with the code
+// its failures share, or exitFailure when they failed for different reasons.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var batch *batchError
+	if errors.As(err, &batch) {
+		code := exitCode(batch.errs[0])
+		for _, err := range batch.errs[1:] {
+			if exitCode(err) != code {
+				return exitFailure
+			}
+		}
+		return code
+	}
+	for _, exit := range exitCodes {
+		if errors.Is(err, exit.err) {
+			return exit.code
+		}
+	}
+	return exitFailure
+}
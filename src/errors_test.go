@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{name: "success", err: nil, expected: exitOK},
+		{name: "unknown", err: errors.New("boom"), expected: exitFailure},
+		{name: "validation", err: validate(PkgBuild{}), expected: exitValidation},
+		{name: "already published", err: ErrAlreadyPublished, expected: exitAlreadyPublished},
+		{name: "checksum mismatch", err: fmt.Errorf("different x86_64 checksums: %w", ErrChecksumMismatch), expected: exitChecksumMismatch},
+		{name: "downgrade", err: fmt.Errorf("%w: 1.0.0 is older than 2.0.0", ErrDowngrade), expected: exitDowngrade},
+		{name: "remote unavailable", err: fmt.Errorf("%w: %w", ErrRemoteUnavailable, statusError{StatusCode: 503}), expected: exitRemoteUnavailable},
+		{name: "not maintainer", err: fmt.Errorf("%w: test is orphaned", ErrNotMaintainer), expected: exitNotMaintainer},
+		{name: "batch of one kind", err: &batchError{total: 3, errs: []error{ErrAlreadyPublished, ErrAlreadyPublished}}, expected: exitAlreadyPublished},
+		{name: "batch of mixed kinds", err: &batchError{total: 3, errs: []error{ErrAlreadyPublished, ErrDowngrade}}, expected: exitFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, exitCode(tt.err))
+		})
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	err := validate(PkgBuild{CliName: "test"})
+
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "Maintainers", validationErr.Field)
+	assert.EqualError(t, err, "At least one Maintainer is required")
+	assert.ErrorIs(t, err, ErrValidation)
+}
+
+func TestBatchError(t *testing.T) {
+	err := &batchError{total: 3, errs: []error{errors.New("boom"), ErrDowngrade}}
+
+	assert.EqualError(t, err, "2 of 3 packages failed")
+	assert.ErrorIs(t, err, ErrDowngrade)
+}
+
+func TestClient_Get_RemoteUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := DummyClient(server)
+	client.tries = 2
+	_, err := client.Get(context.Background(), server.URL)
+
+	assert.ErrorIs(t, err, ErrRemoteUnavailable)
+	var status statusError
+	assert.ErrorAs(t, err, &status)
+	assert.Equal(t, http.StatusServiceUnavailable, status.StatusCode)
+
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	_, err = client.Get(context.Background(), server.URL)
+	assert.NotErrorIs(t, err, ErrRemoteUnavailable)
+}
+
+func TestClient_Get_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	client := DummyClient(server)
+	client.tries = 3
+	_, err := client.Get(context.Background(), server.URL)
+
+	assert.ErrorIs(t, err, ErrRemoteUnavailable)
+	assert.Contains(t, err.Error(), "connection refused")
+	assert.Equal(t, exitRemoteUnavailable, exitCode(err))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.Get(ctx, server.URL)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, ErrRemoteUnavailable)
+}
+
+func TestDefaultCompareWithRemote_Downgrade(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"resultcount":1,"results":[{"Name":"test","Version":"1.1.0-1"}]}`))
+	}))
+	defer server.Close()
+
+	remote, err := defaultCompareWithRemote(context.Background(), DummyClient(server), PkgBuild{Pkgname: "test", Version: "1.0.0"}, "")
+
+	assert.ErrorIs(t, err, ErrDowngrade)
+	assert.EqualError(t, err, "version is older than the AUR version: 1.0.0 is older than 1.1.0 on the AUR")
+	assert.Equal(t, remoteState{}, remote)
+}
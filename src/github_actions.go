@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// githubActions reports a run to the GitHub Actions workflow it runs in. A nil
+// githubActions, outside of a workflow, reports nothing.
+type githubActions struct {
+	out         io.Writer
+	outputPath  string
+	summaryPath string
+}
+
+func detectGitHubActions(env config) *githubActions {
+	if !env.bool("GITHUB_ACTIONS") {
+		return nil
+	}
+	return &githubActions{
+		out:         os.Stdout,
+		outputPath:  env("GITHUB_OUTPUT"),
+		summaryPath: env("GITHUB_STEP_SUMMARY"),
+	}
+}
+
+var (
+	annotationData     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	annotationProperty = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+func (gha *githubActions) annotate(level, title, message string) {
+	if gha == nil {
+		return
+	}
+	fmt.Fprintf(gha.out, "::%s title=%s::%s\n", level, annotationProperty.Replace(title), annotationData.Replace(message))
+}
+
+func (gha *githubActions) error(title string, err error) {
+	gha.annotate("error", title, err.Error())
+}
+
+func (gha *githubActions) warning(title, message string) {
+	gha.annotate("warning", title, message)
+}
+
+// setOutputs appends the outputs, given as name and value pairs, to $GITHUB_OUTPUT.
+func (gha *githubActions) setOutputs(outputs ...string) error {
+	if gha == nil || gha.outputPath == "" {
+		return nil
+	}
+	var content strings.Builder
+	for i := 0; i+1 < len(outputs); i += 2 {
+		name, value := outputs[i], outputs[i+1]
+		if strings.Contains(value, "\n") {
+			delimiter := "RELEASE_AUR_EOF"
+			for strings.Contains(value, delimiter) {
+				delimiter += "_"
+			}
+			fmt.Fprintf(&content, "%s<<%s\n%s\n%s\n", name, delimiter, value, delimiter)
+			continue
+		}
+		fmt.Fprintf(&content, "%s=%s\n", name, value)
+	}
+	return appendFile(gha.outputPath, content.String())
+}
+
+func (gha *githubActions) writeSummary(markdown string) error {
+	if gha == nil || gha.summaryPath == "" {
+		return nil
+	}
+	return appendFile(gha.summaryPath, markdown)
+}
+
+// reportRun annotates the warnings of a run and writes its outputs and step summary.
+func (gha *githubActions) reportRun(report runReport, reportPath string) {
+	if gha == nil {
+		return
+	}
+	for _, warning := range report.Warnings {
+		gha.warning(report.Pkgname, warning)
+	}
+	if err := gha.setOutputs(
+		"pkgbuild_path", report.Files.PKGBUILD,
+		"srcinfo_path", report.Files.SRCINFO,
+		"install_path", report.Files.Install,
+		"changelog_path", report.Files.Changelog,
+		"local_source_paths", strings.Join(report.Files.LocalSources, "\n"),
+		"report_path", absPath(reportPath),
+		"pkgrel", report.Pkgrel,
+		"decision", report.Decision,
+		"up_to_date", strconv.FormatBool(report.UpToDate),
+	); err != nil {
+		slog.Warn("Failed to write the step outputs", "err", err)
+	}
+	if err := gha.writeSummary(runSummary(report)); err != nil {
+		slog.Warn("Failed to write the step summary", "err", err)
+	}
+}
+
+// reportBatch annotates the failures and warnings of a batch and writes its
+// outputs and step summary.
+func (gha *githubActions) reportBatch(results []batchResult, reportPath string) {
+	if gha == nil {
+		return
+	}
+	var summary strings.Builder
+	summary.WriteString("## AUR packages\n\n| Package | Version | Decision | Status |\n|---|---|---|---|\n")
+	for _, result := range results {
+		if result.Err != nil {
+			gha.error(result.Pkgname, result.Err)
+			fmt.Fprintf(&summary, "| %s | %s | | :x: %s |\n", result.Pkgname, result.Version, markdownCell(result.Err.Error()))
+			continue
+		}
+		for _, warning := range result.Report.Warnings {
+			gha.warning(result.Pkgname, warning)
+		}
+		fmt.Fprintf(&summary, "| %s | %s-%s | %s | :white_check_mark: |\n", result.Pkgname, result.Version, result.Pkgrel, result.Report.Decision)
+	}
+	summary.WriteString("\n")
+	for _, result := range results {
+		if result.Report != nil {
+			summary.WriteString(runSummary(*result.Report))
+		}
+	}
+
+	if err := gha.setOutputs("report_path", absPath(reportPath)); err != nil {
+		slog.Warn("Failed to write the step outputs", "err", err)
+	}
+	if err := gha.writeSummary(summary.String()); err != nil {
+		slog.Warn("Failed to write the step summary", "err", err)
+	}
+}
+
+func runSummary(report runReport) string {
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "## %s %s-%s\n\n", report.Pkgname, report.Pkgver, report.Pkgrel)
+	switch report.Decision {
+	case decisionNewPackage:
+		summary.WriteString("New package on the AUR.\n\n")
+	case decisionNewVersion:
+		fmt.Fprintf(&summary, "New version, the AUR has %s.\n\n", report.RemoteVersion)
+	case decisionPkgrelBump:
+		fmt.Fprintf(&summary, "pkgrel bump, the AUR has %s.\n\n", report.RemoteVersion)
+	case decisionUpToDate:
+		fmt.Fprintf(&summary, "Already published, the AUR has %s and nothing needed to change.\n\n", report.RemoteVersion)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(&summary, "> [!WARNING]\n> %s\n\n", warning)
+	}
+
+	if len(report.Sources) != 0 {
+		summary.WriteString("| Source | Arch | sha256 |\n|---|---|---|\n")
+		for _, source := range report.Sources {
+			fmt.Fprintf(&summary, "| [%s](%s) | %s | `%s` |\n", markdownCell(source.Name), source.URL, source.Arch, source.Sha256)
+		}
+		summary.WriteString("\n")
+	}
+	if report.Diff != "" {
+		fmt.Fprintf(&summary, "<details><summary>Changes to the published PKGBUILD</summary>\n\n```diff\n%s```\n\n</details>\n\n", report.Diff)
+	}
+	return summary.String()
+}
+
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(text)
+}
+
+func appendFile(path, content string) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
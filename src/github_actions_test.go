@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestGitHubActions(t *testing.T) (*githubActions, *bytes.Buffer) {
+	dir := t.TempDir()
+	var out bytes.Buffer
+	return &githubActions{
+		out:         &out,
+		outputPath:  filepath.Join(dir, "output"),
+		summaryPath: filepath.Join(dir, "summary"),
+	}, &out
+}
+
+func readFile(t *testing.T, path string) string {
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestDetectGitHubActions(t *testing.T) {
+	assert.Nil(t, detectGitHubActions(func(string) string { return "" }))
+
+	gha := detectGitHubActions(func(key string) string {
+		return map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_OUTPUT": "/tmp/output", "GITHUB_STEP_SUMMARY": "/tmp/summary"}[key]
+	})
+	assert.NotNil(t, gha)
+	assert.Equal(t, "/tmp/output", gha.outputPath)
+	assert.Equal(t, "/tmp/summary", gha.summaryPath)
+}
+
+func TestGitHubActions_Annotations(t *testing.T) {
+	gha, out := newTestGitHubActions(t)
+
+	gha.error("Validation failed", errors.New("Pkgname is required\n100% sure"))
+	gha.warning("pkg: test, other", "flagged out of date")
+
+	assert.Equal(t, "::error title=Validation failed::Pkgname is required%0A100%25 sure\n"+
+		"::warning title=pkg%3A test%2C other::flagged out of date\n", out.String())
+
+	var disabled *githubActions
+	disabled.error("Validation failed", errors.New("ignored"))
+	assert.NoError(t, disabled.setOutputs("pkgrel", "1"))
+	assert.NoError(t, disabled.writeSummary("ignored"))
+}
+
+func TestGitHubActions_SetOutputs(t *testing.T) {
+	gha, _ := newTestGitHubActions(t)
+
+	assert.NoError(t, gha.setOutputs("pkgrel", "2", "diff", "-a\n+b"))
+	assert.NoError(t, gha.setOutputs("decision", "pkgrel-bump"))
+
+	assert.Equal(t, "pkgrel=2\ndiff<<RELEASE_AUR_EOF\n-a\n+b\nRELEASE_AUR_EOF\ndecision=pkgrel-bump\n", readFile(t, gha.outputPath))
+}
+
+func TestGitHubActions_ReportRun(t *testing.T) {
+	gha, out := newTestGitHubActions(t)
+	report := runReport{
+		Version:       reportVersion,
+		Pkgname:       "test-bin",
+		Pkgver:        "1.0.0",
+		Pkgrel:        "2",
+		Decision:      decisionPkgrelBump,
+		RemoteVersion: "1.0.0-1",
+		Sources:       []reportSource{{Arch: "x86_64", Name: "test-1.0.0-x86_64", URL: "https://example.com/test", Sha256: "abc"}},
+		Files:         reportFiles{PKGBUILD: "/out/PKGBUILD", SRCINFO: "/out/.SRCINFO", Install: "/out/test-bin.install", Changelog: "/out/CHANGELOG.md", LocalSources: []string{"/out/test.service", "/out/test.desktop"}},
+		Warnings:      []string{"test-bin is orphaned on the AUR"},
+		Diff:          "-pkgrel=1\n+pkgrel=2\n",
+	}
+
+	gha.reportRun(report, "/tmp/report.json")
+
+	assert.Equal(t, "::warning title=test-bin::test-bin is orphaned on the AUR\n", out.String())
+	assert.Equal(t, "pkgbuild_path=/out/PKGBUILD\nsrcinfo_path=/out/.SRCINFO\ninstall_path=/out/test-bin.install\nchangelog_path=/out/CHANGELOG.md\nlocal_source_paths<<RELEASE_AUR_EOF\n/out/test.service\n/out/test.desktop\nRELEASE_AUR_EOF\nreport_path=/tmp/report.json\npkgrel=2\ndecision=pkgrel-bump\nup_to_date=false\n", readFile(t, gha.outputPath))
+	assert.Equal(t, "## test-bin 1.0.0-2\n\n"+
+		"pkgrel bump, the AUR has 1.0.0-1.\n\n"+
+		"> [!WARNING]\n> test-bin is orphaned on the AUR\n\n"+
+		"| Source | Arch | sha256 |\n|---|---|---|\n"+
+		"| [test-1.0.0-x86_64](https://example.com/test) | x86_64 | `abc` |\n\n"+
+		"<details><summary>Changes to the published PKGBUILD</summary>\n\n```diff\n-pkgrel=1\n+pkgrel=2\n```\n\n</details>\n\n", readFile(t, gha.summaryPath))
+}
+
+func TestGitHubActions_ReportBatch(t *testing.T) {
+	gha, out := newTestGitHubActions(t)
+	results := []batchResult{
+		{Pkgname: "first-bin", Version: "1.0.0", Pkgrel: "1", Report: &runReport{Pkgname: "first-bin", Pkgver: "1.0.0", Pkgrel: "1", Decision: decisionNewPackage}},
+		{Pkgname: "second-bin", Version: "1.0.0", Err: errors.New("different | checksums")},
+	}
+
+	gha.reportBatch(results, "/tmp/report.json")
+
+	assert.Equal(t, "::error title=second-bin::different | checksums\n", out.String())
+	assert.Equal(t, "report_path=/tmp/report.json\n", readFile(t, gha.outputPath))
+	assert.Equal(t, "## AUR packages\n\n| Package | Version | Decision | Status |\n|---|---|---|---|\n"+
+		"| first-bin | 1.0.0-1 | new-package | :white_check_mark: |\n"+
+		"| second-bin | 1.0.0 | | :x: different \\| checksums |\n\n"+
+		"## first-bin 1.0.0-1\n\nNew package on the AUR.\n\n", readFile(t, gha.summaryPath))
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// httpCache keeps downloaded bodies on disk so a re-run only has to revalidate
+// them with If-None-Match / If-Modified-Since instead of downloading again.
+// Every URL gets a <key>.body file and a <key>.json file holding cacheEntry.
+type httpCache struct {
+	dir string
+	// sums maps every URL whose body was loaded or stored this run to the
+	// checksum of that body, so it is not calculated again.
+	sums *sync.Map
+}
+
+func newHTTPCache(dir string) *httpCache {
+	return &httpCache{dir: dir, sums: &sync.Map{}}
+}
+
+// checksum returns the sha256 of the last body loaded or stored for url.
+func (cache httpCache) checksum(url string) (string, bool) {
+	sum, ok := cache.sums.Load(url)
+	if !ok {
+		return "", false
+	}
+	return sum.(string), true
+}
+
+type cacheEntry struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// Sha256 is the checksum of the body, it is checked on every read so a
+	// truncated or tampered body is downloaded again rather than used.
+	Sha256 string `json:"sha256"`
+}
+
+func cacheKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+func (cache httpCache) paths(url string) (body, meta string) {
+	key := cacheKey(url)
+	return filepath.Join(cache.dir, key+".body"), filepath.Join(cache.dir, key+".json")
+}
+
+// load returns the cached entry for url, ok is false when there is none or
+// when the body no longer matches its checksum.
+func (cache httpCache) load(url string) (cacheEntry, []byte, bool) {
+	bodyPath, metaPath := cache.paths(url)
+	var entry cacheEntry
+	meta, err := os.ReadFile(metaPath)
+	if err != nil {
+		return entry, nil, false
+	}
+	if err := json.Unmarshal(meta, &entry); err != nil || entry.URL != url {
+		return entry, nil, false
+	}
+	body, err := os.ReadFile(bodyPath)
+	if err != nil {
+		return entry, nil, false
+	}
+	sum := sha256.Sum256(body)
+	if hex.EncodeToString(sum[:]) != entry.Sha256 {
+		slog.Warn("Ignoring cached body with a wrong checksum", "url", url)
+		return entry, nil, false
+	}
+	cache.sums.Store(url, entry.Sha256)
+	return entry, body, true
+}
+
+// store saves body for url when the response can be revalidated later.
+func (cache httpCache) store(url string, header http.Header, body []byte) error {
+	cache.sums.Delete(url)
+	entry := cacheEntry{
+		URL:          url,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+	}
+	if entry.ETag == "" && entry.LastModified == "" {
+		return nil
+	}
+	sum := sha256.Sum256(body)
+	entry.Sha256 = hex.EncodeToString(sum[:])
+	cache.sums.Store(url, entry.Sha256)
+
+	meta, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cache.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	bodyPath, metaPath := cache.paths(url)
+	if err := writeFileAtomic(bodyPath, body); err != nil {
+		return err
+	}
+	return writeFileAtomic(metaPath, meta)
+}
+
+// revalidate adds the conditional headers for a cached entry to req.
+func (entry cacheEntry) revalidate(req *http.Request) {
+	if entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
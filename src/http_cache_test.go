@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_GetCache(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		value       string
+		conditional string
+	}{
+		{name: "etag", header: "ETag", value: `"v1"`, conditional: "If-None-Match"},
+		{name: "last modified", header: "Last-Modified", value: "Wed, 01 Jan 2025 12:00:00 GMT", conditional: "If-Modified-Since"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloads := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(tt.header, tt.value)
+				if r.Header.Get(tt.conditional) == tt.value {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				downloads++
+				w.Write([]byte("artifact"))
+			}))
+			defer server.Close()
+
+			client := DummyClient(server).WithCache(t.TempDir())
+			for range 3 {
+				body, err := client.Get(context.Background(), server.URL+"/artifact")
+				assert.NoError(t, err)
+				assert.Equal(t, "artifact", string(body))
+			}
+			assert.Equal(t, 1, downloads)
+			sum := sha256.Sum256([]byte("artifact"))
+			checksum, ok := client.Checksum(server.URL + "/artifact")
+			assert.True(t, ok)
+			assert.Equal(t, hex.EncodeToString(sum[:]), checksum)
+		})
+	}
+
+	t.Run("changed upstream", func(t *testing.T) {
+		version := "v1"
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			etag := `"` + version + `"`
+			w.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte(version))
+		}))
+		defer server.Close()
+
+		client := DummyClient(server).WithCache(t.TempDir())
+		body, _ := client.Get(context.Background(), server.URL)
+		assert.Equal(t, "v1", string(body))
+		version = "v2"
+		body, err := client.Get(context.Background(), server.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, "v2", string(body))
+		sum := sha256.Sum256([]byte("v2"))
+		checksum, _ := client.Checksum(server.URL)
+		assert.Equal(t, hex.EncodeToString(sum[:]), checksum)
+	})
+
+	t.Run("corrupted body", func(t *testing.T) {
+		downloads := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") != "" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			downloads++
+			w.Write([]byte("artifact"))
+		}))
+		defer server.Close()
+
+		cache := newHTTPCache(t.TempDir())
+		client := DummyClient(server).WithCache(cache.dir)
+		client.Get(context.Background(), server.URL)
+		bodyPath, _ := cache.paths(server.URL)
+		assert.NoError(t, os.WriteFile(bodyPath, []byte("truncat"), 0644))
+
+		body, err := client.Get(context.Background(), server.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, "artifact", string(body))
+		assert.Equal(t, 2, downloads)
+	})
+
+	t.Run("no validators", func(t *testing.T) {
+		dir := t.TempDir()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("rpc"))
+		}))
+		defer server.Close()
+
+		client := DummyClient(server).WithCache(dir)
+		_, err := client.Get(context.Background(), server.URL)
+		assert.NoError(t, err)
+		entries, _ := os.ReadDir(dir)
+		assert.Empty(t, entries)
+		_, ok := client.Checksum(server.URL)
+		assert.False(t, ok)
+	})
+}
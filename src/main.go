@@ -1,23 +1,58 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// dump-templates [dir] writes the built-in templates as a starting point
+	if len(os.Args) > 1 && os.Args[1] == "dump-templates" {
+		dir := "templates"
+		if len(os.Args) > 2 {
+			dir = os.Args[2]
+		}
+		if err := dumpTemplates(dir); err != nil {
+			slog.Error("Dumping templates failed", "err", err)
+			os.Exit(exitFailure)
+		}
+		slog.Info("Dumped the built-in templates", "dir", dir)
+		return
+	}
+
+	gha := detectGitHubActions(os.Getenv)
+
+	if manifest := os.Getenv("manifest"); manifest != "" {
+		if err := generateBatch(ctx, manifest, os.Getenv); err != nil {
+			slog.Error("Batch generation failed", "err", err)
+			gha.error("Batch generation failed", err)
+			os.Exit(exitCode(err))
+		}
+		slog.Info("All PKGBUILDs updated successfully")
+		return
+	}
+
 	pkgbuild := NewPkgBuildFromEnv()
 
 	slog.Info("Validating", "pkgbuild", pkgbuild)
 	if err := validate(*pkgbuild); err != nil {
 		slog.Error("Validation failed", "err", err)
-		os.Exit(1)
+		gha.error("Validation failed", err)
+		os.Exit(exitCode(err))
 	}
 	slog.Info("pkgbuild is valid")
 
-	if _, err := pkgbuild.generate(); err != nil {
+	if _, err := pkgbuild.generate(ctx); err != nil {
 		slog.Error("Generation failed", "err", err)
-		os.Exit(1)
+		gha.error("Generation failed", err)
+		os.Exit(exitCode(err))
 	}
+	gha.reportRun(pkgbuild.report, pkgbuild.reportPath)
 	slog.Info("PKGBUILD updated successfully")
 }
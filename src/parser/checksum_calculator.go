@@ -20,22 +20,29 @@ func CalculateSHA256(data io.Reader) (string, error) {
 	return fmt.Sprintf("%x", hash.Sum(nil)), nil
 }
 
-type CalculateSources func(get func(string) ([]byte, error), sources []string) ([]string, error)
+// CalculateSources returns the checksum of every source, downloading them with get.
+// sums returns the checksum of a download get already checked, like a cached one,
+// so its body is not hashed again. It can be nil.
+type CalculateSources func(get func(string) ([]byte, error), sums func(string) (string, bool), sources []string) ([]string, error)
 
-func DefaultCalculateSources(get func(string) ([]byte, error), sources []string) ([]string, error) {
+func DefaultCalculateSources(get func(string) ([]byte, error), sums func(string) (string, bool), sources []string) ([]string, error) {
 	checksums := make([]string, len(sources))
 
 	for i, source := range sources {
-		url := source
-
-		if idx := strings.LastIndex(source, "::"); idx != -1 {
-			url = source[idx+2:]
-		}
+		_, url := SplitSource(source)
 		body, err := get(url)
 		if err != nil {
 			return nil, fmt.Errorf("failed to download source %v: %w", url, err)
 		}
 
+		if sums != nil {
+			if checksum, ok := sums(url); ok {
+				checksums[i] = checksum
+				slog.Info("Using checksum of cached download", "source", source, "sha256", checksum)
+				continue
+			}
+		}
+
 		checksum, err := CalculateSHA256(bytes.NewReader(body))
 		if err != nil {
 			return nil, fmt.Errorf("failed to checksum source %d: %w", i, err)
@@ -47,3 +54,12 @@ func DefaultCalculateSources(get func(string) ([]byte, error), sources []string)
 
 	return checksums, nil
 }
+
+// SplitSource splits a PKGBUILD source entry of the form "name::url" into its parts,
+// name is empty when the source has no rename prefix.
+func SplitSource(source string) (string, string) {
+	if idx := strings.LastIndex(source, "::"); idx != -1 {
+		return source[:idx], source[idx+2:]
+	}
+	return "", source
+}
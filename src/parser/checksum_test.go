@@ -25,13 +25,25 @@ func TestCalculateForSourcesReal(t *testing.T) {
 			return []byte{}, err
 		}
 		return body, nil
-	}, sources)
+	}, nil, sources)
 
 	assert.NoError(t, err)
 	// checksum from github
 	assert.Equal(t, []string{"ba62160a8721ea41c112adc3cd369e1c7abb9d1c03d2bd89d13740b420cc1cc6"}, result)
 }
 
+func TestCalculateForSources_KnownChecksum(t *testing.T) {
+	sums := func(url string) (string, bool) {
+		return "cached", url == "https://example.com/cached"
+	}
+	result, err := DefaultCalculateSources(func(string) ([]byte, error) {
+		return []byte("test"), nil
+	}, sums, []string{"https://example.com/cached", "https://example.com/fresh"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cached", "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}, result)
+}
+
 func TestCalculateForSources(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -99,7 +111,7 @@ func TestCalculateForSources(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := DefaultCalculateSources(tt.clientMock, tt.sources)
+			result, err := DefaultCalculateSources(tt.clientMock, nil, tt.sources)
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned when a downloaded source does not match its published checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// bsdChecksumLine matches the BSD (and `sha256sum --tag`) format "SHA256 (<filename>) = <checksum>".
+var bsdChecksumLine = regexp.MustCompile(`^([A-Za-z0-9-]+) \((.+)\) = ([0-9A-Fa-f]+)$`)
+
+// ParseChecksumsFile parses a sha256 checksums file in either the GNU coreutils
+// ("<checksum>  <filename>") or the BSD ("SHA256 (<filename>) = <checksum>") format
+// into a map of filename to checksum. BSD lines for other algorithms are ignored.
+func ParseChecksumsFile(content string) (map[string]string, error) {
+	checksums := make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var checksum, filename string
+		if match := bsdChecksumLine.FindStringSubmatch(line); match != nil {
+			if !strings.EqualFold(match[1], "SHA256") {
+				continue
+			}
+			filename, checksum = match[2], match[3]
+		} else {
+			// GNU escapes filenames containing a backslash or newline with a leading '\'
+			line = strings.TrimPrefix(line, "\\")
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("invalid checksums line %q", line)
+			}
+			// binary mode entries are prefixed with '*'
+			checksum, filename = fields[0], strings.TrimPrefix(fields[1], "*")
+		}
+
+		if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != 32 {
+			return nil, fmt.Errorf("invalid sha256 checksum %q for %s", checksum, filename)
+		}
+		checksums[path.Base(filename)] = strings.ToLower(checksum)
+	}
+
+	return checksums, scanner.Err()
+}
+
+// NewChecksumsFileCalculator returns a CalculateSources that looks up the checksum of
+// every source in the published checksums files, downloading only the sources that
+// are missing from them. With verify set every source is downloaded as well and its
+// checksum has to match the published one.
+func NewChecksumsFileCalculator(checksumsURLs []string, verify bool) CalculateSources {
+	return func(get func(string) ([]byte, error), sums func(string) (string, bool), sources []string) ([]string, error) {
+		checksums := make([]string, len(sources))
+		if len(sources) == 0 {
+			return checksums, nil
+		}
+
+		published := make(map[string]string)
+		for _, checksumsURL := range checksumsURLs {
+			body, err := get(checksumsURL)
+			if err != nil {
+				return nil, fmt.Errorf("failed to download checksums file %v: %w", checksumsURL, err)
+			}
+			parsed, err := ParseChecksumsFile(string(body))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse checksums file %v: %w", checksumsURL, err)
+			}
+			for filename, checksum := range parsed {
+				published[filename] = checksum
+			}
+		}
+
+		for i, source := range sources {
+			_, url := SplitSource(source)
+			checksum, ok := published[path.Base(url)]
+			if ok && !verify {
+				slog.Info("Found checksum in checksums file", "source", source, "sha256", checksum)
+				checksums[i] = checksum
+				continue
+			}
+			if !ok {
+				slog.Info("Source not found in checksums file, downloading it", "source", source)
+			}
+
+			calculated, err := DefaultCalculateSources(get, sums, []string{source})
+			if err != nil {
+				return nil, err
+			}
+			if ok && calculated[0] != checksum {
+				return nil, fmt.Errorf("%w for source %v: published %s, downloaded %s", ErrChecksumMismatch, url, checksum, calculated[0])
+			}
+			checksums[i] = calculated[0]
+		}
+
+		return checksums, nil
+	}
+}
@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChecksumsFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]string
+		wantErr  bool
+	}{
+		{
+			name: "sha256sum output",
+			input: `ba62160a8721ea41c112adc3cd369e1c7abb9d1c03d2bd89d13740b420cc1cc6  release-aur-linux-amd64
+6AE8A75555209FD6C44157C0AED8016E763FF435A19CF186F76863140143FF72 *dist/release-aur-linux-arm64
+`,
+			expected: map[string]string{
+				"release-aur-linux-amd64": "ba62160a8721ea41c112adc3cd369e1c7abb9d1c03d2bd89d13740b420cc1cc6",
+				"release-aur-linux-arm64": "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72",
+			},
+		},
+		{
+			name: "BSD format",
+			input: `SHA256 (release-aur-linux-amd64) = ba62160a8721ea41c112adc3cd369e1c7abb9d1c03d2bd89d13740b420cc1cc6
+MD5 (release-aur-linux-amd64) = 5d41402abc4b2a76b9719d911017c592
+SHA256 (dir/with space) = 6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72`,
+			expected: map[string]string{
+				"release-aur-linux-amd64": "ba62160a8721ea41c112adc3cd369e1c7abb9d1c03d2bd89d13740b420cc1cc6",
+				"with space":              "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72",
+			},
+		},
+		{
+			name:     "comments, blank lines and escaped filenames",
+			input:    "# generated\n\n\\ba62160a8721ea41c112adc3cd369e1c7abb9d1c03d2bd89d13740b420cc1cc6  file\n",
+			expected: map[string]string{"file": "ba62160a8721ea41c112adc3cd369e1c7abb9d1c03d2bd89d13740b420cc1cc6"},
+		},
+		{
+			name:    "not a sha256 checksum",
+			input:   "5d41402abc4b2a76b9719d911017c592  file",
+			wantErr: true,
+		},
+		{
+			name:    "invalid line",
+			input:   "abc",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseChecksumsFile(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestNewChecksumsFileCalculator(t *testing.T) {
+	checksumsURL := "https://example.com/checksums.txt"
+	fileChecksum := "d0b425e00e15a0d36b9b361f02bab63563aed6cb4665083905386c55d5b679fa"
+	licenseChecksum := "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72"
+	get := func(url string) ([]byte, error) {
+		switch url {
+		case checksumsURL:
+			return []byte(fileChecksum + "  file1\n"), nil
+		case "https://example.com/SHA256SUMS":
+			return []byte("SHA256 (LICENSE) = " + licenseChecksum + "\n"), nil
+		case "https://example.com/file1":
+			return []byte("content1"), nil
+		case "https://example.com/LICENSE":
+			return []byte("test content"), nil
+		case "https://example.com/broken.txt":
+			return []byte("not a checksums file"), nil
+		}
+		return nil, fmt.Errorf("404 Not Found")
+	}
+
+	t.Run("published and downloaded checksums", func(t *testing.T) {
+		result, err := NewChecksumsFileCalculator([]string{checksumsURL}, false)(get, nil, []string{
+			"pkg-1.0.0-x86_64::https://example.com/file1",
+			"https://example.com/LICENSE",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{fileChecksum, licenseChecksum}, result)
+	})
+
+	t.Run("multiple checksums files", func(t *testing.T) {
+		result, err := NewChecksumsFileCalculator([]string{checksumsURL, "https://example.com/SHA256SUMS"}, false)(func(url string) ([]byte, error) {
+			if url == "https://example.com/file1" || url == "https://example.com/LICENSE" {
+				return nil, fmt.Errorf("should not download %s", url)
+			}
+			return get(url)
+		}, nil, []string{"https://example.com/file1", "https://example.com/LICENSE"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{fileChecksum, licenseChecksum}, result)
+	})
+
+	t.Run("verify against the download", func(t *testing.T) {
+		result, err := NewChecksumsFileCalculator([]string{checksumsURL}, true)(get, nil, []string{"https://example.com/file1"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{fileChecksum}, result)
+	})
+
+	t.Run("verify detects a mismatch", func(t *testing.T) {
+		_, err := NewChecksumsFileCalculator([]string{"https://example.com/SHA256SUMS"}, true)(func(url string) ([]byte, error) {
+			if url == "https://example.com/LICENSE" {
+				return []byte("tampered"), nil
+			}
+			return get(url)
+		}, nil, []string{"https://example.com/LICENSE"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "checksum mismatch for source https://example.com/LICENSE")
+	})
+
+	t.Run("no sources does not fetch the checksums file", func(t *testing.T) {
+		result, err := NewChecksumsFileCalculator([]string{"https://example.com/missing"}, false)(get, nil, []string{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{}, result)
+	})
+
+	t.Run("checksums file download fails", func(t *testing.T) {
+		_, err := NewChecksumsFileCalculator([]string{"https://example.com/missing"}, false)(get, nil, []string{"https://example.com/file1"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to download checksums file")
+	})
+
+	t.Run("missing source download fails", func(t *testing.T) {
+		_, err := NewChecksumsFileCalculator([]string{checksumsURL}, false)(get, nil, []string{"https://example.com/file2"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to download source")
+	})
+
+	t.Run("invalid checksums file", func(t *testing.T) {
+		_, err := NewChecksumsFileCalculator([]string{"https://example.com/broken.txt"}, false)(get, nil, []string{"https://example.com/file1"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid checksums line")
+	})
+}
@@ -7,37 +7,51 @@ import (
 )
 
 func ExtractChecksums(pkgbuildContent string) (map[string][]string, error) {
-	checksums := make(map[string][]string)
+	return extractArrays(pkgbuildContent, "sha256sums")
+}
+
+// ExtractSources returns the source arrays of a PKGBUILD by arch, the arch
+// independent source=() is stored under the empty arch.
+func ExtractSources(pkgbuildContent string) (map[string][]string, error) {
+	return extractArrays(pkgbuildContent, "source")
+}
+
+// extractArrays returns the values of the name and name_<arch> arrays by arch.
+func extractArrays(pkgbuildContent, name string) (map[string][]string, error) {
+	values := make(map[string][]string)
 
 	scanner := bufio.NewScanner(strings.NewReader(pkgbuildContent))
 	var currentArch string
-	var inChecksumArray bool
+	var inArray bool
 
 	for scanner.Scan() {
 		line := scanner.Text()
 
-		if strings.HasPrefix(line, "sha256sums_") {
-			currentArch = line[len("sha256sums_"):strings.Index(line, "=")]
-			inChecksumArray = true
+		suffix, isArray := strings.CutPrefix(line, name)
+		isArray = isArray && strings.Contains(suffix, "=") && (suffix[0] == '=' || suffix[0] == '_')
+		if isArray {
+			// arch independent arrays are stored under the empty arch
+			currentArch = strings.TrimPrefix(suffix[:strings.Index(suffix, "=")], "_")
+			inArray = true
 
-			checksums[currentArch] = extractChecksumsFromLine(line)
+			values[currentArch] = extractValuesFromLine(line)
 			if strings.HasSuffix(line, ")") {
-				inChecksumArray = false
+				inArray = false
 			}
-		} else if inChecksumArray {
-			moreChecksum := extractChecksumsFromLine(line)
-			checksums[currentArch] = append(checksums[currentArch], moreChecksum...)
+		} else if inArray {
+			moreValues := extractValuesFromLine(line)
+			values[currentArch] = append(values[currentArch], moreValues...)
 			if strings.HasSuffix(line, ")") {
-				inChecksumArray = false
+				inArray = false
 			}
 		}
 	}
 
-	return checksums, scanner.Err()
+	return values, scanner.Err()
 }
 
-func extractChecksumsFromLine(line string) []string {
-	checksums := make([]string, 0)
+func extractValuesFromLine(line string) []string {
+	values := make([]string, 0)
 	if strings.Contains(line, "(") {
 		line = strings.TrimPrefix(line, line[:strings.Index(line, "(")+1])
 	}
@@ -47,11 +61,11 @@ func extractChecksumsFromLine(line string) []string {
 	parts := strings.SplitSeq(line, " ")
 	for part := range parts {
 		if part != "" {
-			checksums = append(checksums, strings.Trim(part, "'"))
+			values = append(values, strings.Trim(part, `'"`))
 		}
 	}
 	line = strings.Trim(line, "'")
-	return checksums
+	return values
 }
 
 func NormalizePKGBUILD(content string) string {
@@ -6,6 +6,23 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func TestExtractSources(t *testing.T) {
+	sources, err := ExtractSources(`pkgname=test
+source=(
+"LICENSE::https://example.com/LICENSE"
+"test.service"
+)
+sourcedir=build
+source_x86_64=("test-1.0.0-x86_64::https://example.com/test-amd64")
+sha256sums=('SKIP' 'SKIP')`)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"":       {"LICENSE::https://example.com/LICENSE", "test.service"},
+		"x86_64": {"test-1.0.0-x86_64::https://example.com/test-amd64"},
+	}, sources)
+}
+
 func TestExtractChecksums(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -66,6 +83,15 @@ sha256sums_aarch64=('SKIP')`,
 				"aarch64": {"SKIP"},
 			},
 		},
+		{
+			name: "arch independent and x86_64",
+			input: `sha256sums=('src123')
+sha256sums_x86_64=('abc123')`,
+			expected: map[string][]string{
+				"":       {"src123"},
+				"x86_64": {"abc123"},
+			},
+		},
 		{
 			name:     "no checksums",
 			input:    `pkgname=test\npkgver=1.0.0`,
@@ -118,7 +144,7 @@ func TestExtractChecksumsFromLine(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := extractChecksumsFromLine(tt.input)
+			result := extractValuesFromLine(tt.input)
 			assert.Equal(t, tt.expected, result)
 		})
 	}
@@ -0,0 +1,39 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// ExtractSrcinfoVersion reads the pkgbase version out of a .SRCINFO file in the
+// same [epoch:]pkgver-pkgrel form the AUR RPC reports it.
+func ExtractSrcinfoVersion(srcinfoContent string) (string, error) {
+	values := make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(srcinfoContent))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "pkgname") {
+			// the version of the pkgbase applies to every package
+			break
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	if values["pkgver"] == "" || values["pkgrel"] == "" {
+		return "", fmt.Errorf("no pkgver or pkgrel in .SRCINFO")
+	}
+	version := values["pkgver"] + "-" + values["pkgrel"]
+	if epoch := values["epoch"]; epoch != "" && epoch != "0" {
+		version = epoch + ":" + version
+	}
+	return version, nil
+}
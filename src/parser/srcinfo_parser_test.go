@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractSrcinfoVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    string
+		errContains string
+	}{
+		{
+			name: "pkgver and pkgrel",
+			input: `pkgbase = test
+	pkgdesc = test package
+	pkgver = 1.2.3
+	pkgrel = 2
+	arch = x86_64
+
+pkgname = test`,
+			expected: "1.2.3-2",
+		},
+		{
+			name: "epoch",
+			input: `pkgbase = test
+	pkgver = 1.2.3
+	pkgrel = 1
+	epoch = 1`,
+			expected: "1:1.2.3-1",
+		},
+		{
+			name: "ignores package sections",
+			input: `pkgbase = test
+	pkgver = 1.0.0
+	pkgrel = 1
+
+pkgname = test
+	pkgver = 9.9.9`,
+			expected: "1.0.0-1",
+		},
+		{
+			name:        "missing pkgrel",
+			input:       "pkgbase = test\n\tpkgver = 1.0.0",
+			errContains: "no pkgver or pkgrel",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := ExtractSrcinfoVersion(tt.input)
+			if tt.errContains != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errContains)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, version)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/fuad-daoud/release-aur/src/parser"
+)
+
+// signatureSuffixes are the detached signature extensions makepkg verifies.
+var signatureSuffixes = []string{".sig", ".asc", ".sign"}
+
+var fingerprintPattern = regexp.MustCompile(`^[0-9A-F]{40}$`)
+
+func signatureExtension(filename string) string {
+	for _, suffix := range signatureSuffixes {
+		if strings.HasSuffix(filename, suffix) {
+			return suffix
+		}
+	}
+	return ""
+}
+
+// sourceFilename is the name makepkg saves a source as.
+func sourceFilename(source string) string {
+	name, url := parser.SplitSource(source)
+	if name != "" {
+		return name
+	}
+	return path.Base(url)
+}
+
+// normalizeFingerprint removes the spaces gpg prints fingerprints with.
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToUpper(strings.ReplaceAll(fingerprint, " ", ""))
+}
+
+// pairSignatures adds a detached signature source after every source whose filename
+// matches pattern, "name::url" gets a "name.sig::url.sig" companion.
+func pairSignatures(sources []string, pattern, suffix string) ([]string, error) {
+	paired := make([]string, 0, len(sources))
+	for _, source := range sources {
+		paired = append(paired, source)
+
+		filename := sourceFilename(source)
+		if signatureExtension(filename) != "" {
+			continue
+		}
+		matched, err := path.Match(pattern, filename)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signed sources pattern %q: %w", pattern, err)
+		}
+		if !matched {
+			continue
+		}
+
+		name, url := parser.SplitSource(source)
+		signature := url + suffix
+		if name != "" {
+			signature = name + suffix + "::" + signature
+		}
+		if !slices.Contains(sources, signature) {
+			paired = append(paired, signature)
+		}
+	}
+	return paired, nil
+}
+
+func (pkgbuild *PkgBuild) pairSignatures() error {
+	var err error
+	if pkgbuild.Source, err = pairSignatures(pkgbuild.Source, pkgbuild.signedSources, pkgbuild.signatureSuffix); err != nil {
+		return err
+	}
+	if pkgbuild.Source_x86_64, err = pairSignatures(pkgbuild.Source_x86_64, pkgbuild.signedSources, pkgbuild.signatureSuffix); err != nil {
+		return err
+	}
+	pkgbuild.Source_aarch64, err = pairSignatures(pkgbuild.Source_aarch64, pkgbuild.signedSources, pkgbuild.signatureSuffix)
+	return err
+}
+
+func loadKeyring(keyringPath string) (openpgp.EntityList, error) {
+	content, err := os.ReadFile(keyringPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("-----BEGIN")) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(content))
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(content))
+}
+
+// verifyDetachedSignature checks an armored or binary detached signature and
+// returns the primary key fingerprint of the signer.
+func verifyDetachedSignature(keyring openpgp.KeyRing, data, signature []byte) (string, error) {
+	var signer *openpgp.Entity
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte("-----BEGIN")) {
+		signer, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(data), bytes.NewReader(signature), nil)
+	} else {
+		signer, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(data), bytes.NewReader(signature), nil)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(signer.PrimaryKey.Fingerprint)), nil
+}
+
+// verifySignatures downloads every signature source and the source it signs and
+// verifies them against the keyring, the signer has to be one of the Validpgpkeys.
+func (pkgbuild PkgBuild) verifySignatures(get func(string) ([]byte, error)) error {
+	keyring, err := loadKeyring(pkgbuild.pgpKeyring)
+	if err != nil {
+		return err
+	}
+
+	for _, sources := range [][]string{pkgbuild.Source, pkgbuild.Source_x86_64, pkgbuild.Source_aarch64} {
+		for _, signatureSource := range sources {
+			signatureName := sourceFilename(signatureSource)
+			suffix := signatureExtension(signatureName)
+			if suffix == "" {
+				continue
+			}
+
+			dataName := strings.TrimSuffix(signatureName, suffix)
+			index := slices.IndexFunc(sources, func(source string) bool { return sourceFilename(source) == dataName })
+			if index == -1 {
+				return fmt.Errorf("no source found for signature %s", signatureName)
+			}
+
+			_, signatureURL := parser.SplitSource(signatureSource)
+			signature, err := get(signatureURL)
+			if err != nil {
+				return fmt.Errorf("failed to download signature %v: %w", signatureURL, err)
+			}
+			_, dataURL := parser.SplitSource(sources[index])
+			data, err := get(dataURL)
+			if err != nil {
+				return fmt.Errorf("failed to download source %v: %w", dataURL, err)
+			}
+
+			fingerprint, err := verifyDetachedSignature(keyring, data, signature)
+			if err != nil {
+				return fmt.Errorf("signature %s does not verify %s: %w", signatureName, dataName, err)
+			}
+			if len(pkgbuild.Validpgpkeys) != 0 && !slices.Contains(pkgbuild.Validpgpkeys, fingerprint) {
+				return fmt.Errorf("%s is signed by %s which is not in validpgpkeys", dataName, fingerprint)
+			}
+			slog.Info("Verified signature", "source", dataName, "signer", fingerprint)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPairSignatures(t *testing.T) {
+	tests := []struct {
+		name     string
+		sources  []string
+		pattern  string
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:    "renamed source",
+			sources: []string{"pkg-1.0.0-x86_64::https://example.com/pkg-linux-amd64", "LICENSE::https://example.com/LICENSE"},
+			pattern: "pkg-*",
+			expected: []string{
+				"pkg-1.0.0-x86_64::https://example.com/pkg-linux-amd64",
+				"pkg-1.0.0-x86_64.sig::https://example.com/pkg-linux-amd64.sig",
+				"LICENSE::https://example.com/LICENSE",
+			},
+		},
+		{
+			name:     "plain source",
+			sources:  []string{"https://example.com/pkg-1.0.0.tar.gz"},
+			pattern:  "*",
+			expected: []string{"https://example.com/pkg-1.0.0.tar.gz", "https://example.com/pkg-1.0.0.tar.gz.sig"},
+		},
+		{
+			name:     "already paired",
+			sources:  []string{"https://example.com/pkg.tar.gz", "https://example.com/pkg.tar.gz.sig"},
+			pattern:  "*",
+			expected: []string{"https://example.com/pkg.tar.gz", "https://example.com/pkg.tar.gz.sig"},
+		},
+		{
+			name:    "invalid pattern",
+			sources: []string{"https://example.com/pkg.tar.gz"},
+			pattern: "[",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := pairSignatures(tt.sources, tt.pattern, ".sig")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func writeTestKeyring(t *testing.T) (*openpgp.Entity, string) {
+	entity, err := openpgp.NewEntity("Test", "", "test@example.com", nil)
+	assert.NoError(t, err)
+
+	var keyring bytes.Buffer
+	assert.NoError(t, entity.Serialize(&keyring))
+	keyringPath := filepath.Join(t.TempDir(), "keyring.gpg")
+	assert.NoError(t, os.WriteFile(keyringPath, keyring.Bytes(), 0644))
+	return entity, keyringPath
+}
+
+func TestVerifySignatures(t *testing.T) {
+	entity, keyringPath := writeTestKeyring(t)
+	fingerprint := strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint))
+	other, _ := openpgp.NewEntity("Other", "", "other@example.com", nil)
+
+	data := []byte("binary content")
+	var signature, armoredSignature, otherSignature bytes.Buffer
+	assert.NoError(t, openpgp.DetachSign(&signature, entity, bytes.NewReader(data), nil))
+	assert.NoError(t, openpgp.ArmoredDetachSign(&armoredSignature, entity, bytes.NewReader(data), nil))
+	assert.NoError(t, openpgp.DetachSign(&otherSignature, other, bytes.NewReader(data), nil))
+
+	files := map[string][]byte{
+		"https://example.com/pkg":       data,
+		"https://example.com/pkg.sig":   signature.Bytes(),
+		"https://example.com/pkg.asc":   armoredSignature.Bytes(),
+		"https://example.com/other.sig": otherSignature.Bytes(),
+		"https://example.com/bad.sig":   []byte("not a signature"),
+	}
+	get := func(url string) ([]byte, error) {
+		if body, ok := files[url]; ok {
+			return body, nil
+		}
+		return nil, fmt.Errorf("404 Not Found")
+	}
+
+	tests := []struct {
+		name        string
+		pkg         PkgBuild
+		errContains string
+	}{
+		{
+			name: "binary signature",
+			pkg: PkgBuild{
+				Source_x86_64: []string{"pkg-1.0.0-x86_64::https://example.com/pkg", "pkg-1.0.0-x86_64.sig::https://example.com/pkg.sig"},
+				Validpgpkeys:  []string{fingerprint},
+			},
+		},
+		{
+			name: "armored signature without validpgpkeys",
+			pkg: PkgBuild{
+				Source: []string{"https://example.com/pkg", "https://example.com/pkg.asc"},
+			},
+		},
+		{
+			name: "signer not in validpgpkeys",
+			pkg: PkgBuild{
+				Source:       []string{"https://example.com/pkg", "https://example.com/pkg.sig"},
+				Validpgpkeys: []string{strings.Repeat("A", 40)},
+			},
+			errContains: "which is not in validpgpkeys",
+		},
+		{
+			name: "unknown signer",
+			pkg: PkgBuild{
+				Source: []string{"pkg::https://example.com/pkg", "pkg.sig::https://example.com/other.sig"},
+			},
+			errContains: "signature pkg.sig does not verify pkg",
+		},
+		{
+			name: "invalid signature",
+			pkg: PkgBuild{
+				Source: []string{"pkg::https://example.com/pkg", "pkg.sig::https://example.com/bad.sig"},
+			},
+			errContains: "does not verify",
+		},
+		{
+			name: "signature without source",
+			pkg: PkgBuild{
+				Source: []string{"https://example.com/pkg.sig"},
+			},
+			errContains: "no source found for signature pkg.sig",
+		},
+		{
+			name: "missing signature",
+			pkg: PkgBuild{
+				Source: []string{"https://example.com/pkg", "https://example.com/pkg.sign"},
+			},
+			errContains: "failed to download signature",
+		},
+		{
+			name: "missing source",
+			pkg: PkgBuild{
+				Source: []string{"pkg.sig::https://example.com/pkg.sig", "pkg::https://example.com/missing"},
+			},
+			errContains: "failed to download source",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.pkg.pgpKeyring = keyringPath
+			err := tt.pkg.verifySignatures(get)
+			if tt.errContains != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errContains)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Run("armored keyring", func(t *testing.T) {
+		var keyring bytes.Buffer
+		writer, err := armor.Encode(&keyring, openpgp.PublicKeyType, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, entity.Serialize(writer))
+		assert.NoError(t, writer.Close())
+		armoredPath := filepath.Join(t.TempDir(), "keyring.asc")
+		assert.NoError(t, os.WriteFile(armoredPath, keyring.Bytes(), 0644))
+
+		pkg := PkgBuild{Source: []string{"https://example.com/pkg", "https://example.com/pkg.sig"}, pgpKeyring: armoredPath}
+		assert.NoError(t, pkg.verifySignatures(get))
+	})
+
+	t.Run("missing keyring", func(t *testing.T) {
+		pkg := PkgBuild{pgpKeyring: "testdata/missing.gpg"}
+		err := pkg.verifySignatures(get)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read keyring")
+	})
+}
+
+func TestMemoizeGet(t *testing.T) {
+	calls := 0
+	get := memoizeGet(func(url string) ([]byte, error) {
+		calls++
+		if url == "fail" {
+			return nil, fmt.Errorf("failed")
+		}
+		return []byte(url), nil
+	})
+
+	for range 2 {
+		body, err := get("https://example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com", string(body))
+		_, err = get("fail")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, 3, calls)
+}
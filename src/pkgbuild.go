@@ -2,10 +2,12 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -19,23 +21,98 @@ type PkgBuild struct {
 	Contributors     []string
 	Pkgname          string
 	Version          string
-	Pkgrel           int
+	Pkgrel           string
 	Description      string
 	Url              string
 	Arch             []string
 	Licence          []string
+	Depends          []string
+	Makedepends      []string
 	Provides         []string
 	Conflicts        []string
+	Source           []string
+	Checksum         []string
 	Source_x86_64    []string
 	Checksum_x86_64  []string
 	Source_aarch64   []string
 	Checksum_aarch64 []string
+	Validpgpkeys     []string
+	Replaces         []string
+	Groups           []string
+	Optdepends       []string
+	Checkdepends     []string
+	Backup           []string
+	Options          []string
+	Noextract        []string
+	// Changelog is the filename of the changelog shipped next to the PKGBUILD
+	Changelog string
 
+	// Mode is either "bin" (prebuilt release binaries) or "source" (build from the release source tarball)
+	Mode        string
+	BuildPreset string
+	SourceDir   string
+
+	// Extra holds free-form values for custom templates, the extra_<name>
+	// settings are reachable as .Extra.<Name>, extra_shell_name as .Extra.ShellName
+	Extra map[string]string
+
+	// Install is the name of the .install file, <pkgname>.install, when the
+	// package has one. PostInstall, PostUpgrade and PreRemove are the scripts
+	// of its functions in the built-in install template
+	Install     string
+	PostInstall string
+	PostUpgrade string
+	PreRemove   string
+
+	// pkgbuildTemplatePath and srcInfoTemplatePath replace the built-in
+	// templates when set, templateName picks the built-in PKGBUILD template
+	// and templateOverride redefines some of its blocks
 	pkgbuildTemplatePath string
+	templateName         string
+	templateOverride     string
 	srcInfoTemplatePath  string
+	installTemplatePath  string
+	changelogPath        string
+	// localSources are paths of files shipped next to the PKGBUILD, like a
+	// systemd unit, they are copied to the output path and added to Source
+	localSources         []string
 	outputPath           string
+	cacheDir             string
+	reportPath           string
+	aurURL               string
+	aurRPCPath           string
+	aurRPCVersion        string
+	aurPlainURL          string
+	aurMirrorURL         string
+	aurUsernames         []string
+	adoptOrphans         bool
+	pkgrelPolicy         pkgrelPolicy
+	idempotent           bool
+	writeRemote          bool
+	releaseManifest      string
+	assetPatterns        map[string]string
+	checksumsURLs        []string
+	verifyChecksums      bool
+	signedSources        string
+	signatureSuffix      string
+	pgpKeyring           string
+	verifiedSources      string
+	sigstoreTrustedRoot  string
+	sigstoreBundleSuffix string
+	sigstoreIdentity     string
+	sigstoreIssuer       string
+	slsaProvenance       string
 	comparator           compareWithRemote
 	checksumCalculator   parser.CalculateSources
+	// installScript is the rendered Install file and changelog the content of
+	// Changelog, filled in by generate
+	installScript string
+	changelog     string
+	// warnings are what generate found off about the package itself, they are
+	// reported with the warnings about the published package
+	warnings []string
+	// report is filled in by generate
+	report runReport
 }
 
 func NewPkgBuild() *PkgBuild {
@@ -45,64 +122,236 @@ func NewPkgBuild() *PkgBuild {
 	}
 }
 func NewPkgBuildFromEnv() *PkgBuild {
+	pkgbuild := newPkgBuildFromConfig(os.Getenv)
+	pkgbuild.Extra = extraVariables(environKeys(), os.Getenv)
+	return pkgbuild
+}
+
+func newPkgBuildFromConfig(env config) *PkgBuild {
 	pkgbuild := NewPkgBuild()
 
-	pkgbuild.Maintainers = strings.Split(os.Getenv("maintainers"), ",")
-	pkgbuild.Contributors = strings.Split(os.Getenv("contributors"), ",")
-	if len(pkgbuild.Contributors) == 1 && pkgbuild.Contributors[0] == "" {
-		pkgbuild.Contributors = []string{}
-	}
-	pkgbuild.CliName = os.Getenv("cli_name")
-	pkgbuild.Pkgname = os.Getenv("pkgname")
-	pkgbuild.Version = os.Getenv("version")
+	pkgbuild.Maintainers = strings.Split(env("maintainers"), ",")
+	pkgbuild.Contributors = env.list("contributors")
+	pkgbuild.CliName = env("cli_name")
+	pkgbuild.Pkgname = env("pkgname")
+	pkgbuild.Version = env("version")
 	if strings.HasPrefix(pkgbuild.Version, "v") && strings.Count(pkgbuild.Version, ".") >= 2 {
 		slog.Info("Version starts with 'v' and contains two or more '.', so removing the 'v'", "count", strings.Count(pkgbuild.Version, "."))
 		pkgbuild.Version = pkgbuild.Version[1:]
 	}
-	pkgbuild.Pkgrel = 1
-	pkgbuild.Description = os.Getenv("description")
-	pkgbuild.Url = os.Getenv("url")
-	pkgbuild.Arch = strings.Split(os.Getenv("arch"), ",")
-	pkgbuild.Licence = strings.Split(os.Getenv("licence"), ",")
-	pkgbuild.Provides = strings.Split(os.Getenv("provides"), ",")
-	if len(pkgbuild.Provides) == 1 && pkgbuild.Provides[0] == "" {
-		pkgbuild.Provides = []string{}
+	pkgbuild.Pkgrel = "1"
+	pkgbuild.Description = env("description")
+	pkgbuild.Url = env("url")
+	pkgbuild.Arch = strings.Split(env("arch"), ",")
+	pkgbuild.Licence = strings.Split(env("licence"), ",")
+	pkgbuild.Depends = env.list("depends")
+	pkgbuild.Makedepends = env.list("makedepends")
+	pkgbuild.Provides = env.list("provides")
+	pkgbuild.Conflicts = env.list("conflicts")
+	pkgbuild.Replaces = env.list("replaces")
+	pkgbuild.Groups = env.list("groups")
+	pkgbuild.Optdepends = env.list("optdepends")
+	pkgbuild.Checkdepends = env.list("checkdepends")
+	pkgbuild.Backup = env.list("backup")
+	pkgbuild.Options = env.list("options")
+	pkgbuild.Noextract = env.list("noextract")
+	pkgbuild.changelogPath = env("changelog")
+	if pkgbuild.changelogPath != "" {
+		pkgbuild.Changelog = filepath.Base(pkgbuild.changelogPath)
 	}
-	pkgbuild.Conflicts = strings.Split(os.Getenv("conflicts"), ",")
-	if len(pkgbuild.Conflicts) == 1 && pkgbuild.Conflicts[0] == "" {
-		pkgbuild.Conflicts = []string{}
+	pkgbuild.Source_x86_64 = env.list("source_x86_64")
+	pkgbuild.Source_aarch64 = env.list("source_aarch64")
+
+	pkgbuild.Mode = env.get("mode", "bin")
+	pkgbuild.BuildPreset = env("build_preset")
+	pkgbuild.SourceDir = env("source_dir")
+	pkgbuild.Source = env.list("source")
+	pkgbuild.localSources = env.list("local_sources")
+
+	pkgbuild.Validpgpkeys = env.list("validpgpkeys")
+	for i, fingerprint := range pkgbuild.Validpgpkeys {
+		pkgbuild.Validpgpkeys[i] = normalizeFingerprint(fingerprint)
+	}
+	pkgbuild.signedSources = env("signed_sources")
+	pkgbuild.signatureSuffix = env.get("signature_suffix", ".sig")
+	pkgbuild.pgpKeyring = env("pgp_keyring")
+
+	pkgbuild.verifiedSources = env.get("verified_sources", "*")
+	pkgbuild.sigstoreTrustedRoot = env("sigstore_trusted_root")
+	pkgbuild.sigstoreBundleSuffix = env("sigstore_bundle_suffix")
+	pkgbuild.sigstoreIdentity = env("sigstore_identity")
+	pkgbuild.sigstoreIssuer = env("sigstore_issuer")
+	pkgbuild.slsaProvenance = env("slsa_provenance")
+
+	pkgbuild.releaseManifest = env("release_manifest")
+	pkgbuild.assetPatterns = map[string]string{}
+	for arch, pattern := range defaultAssetPatterns {
+		pkgbuild.assetPatterns[arch] = env.get("asset_pattern_"+arch, pattern)
+	}
+
+	templateDir := env("template_dir")
+	pkgbuild.pkgbuildTemplatePath = templatePath(env("pkgbuild_template"), templateDir)
+	pkgbuild.templateName = env("template")
+	pkgbuild.templateOverride = env("template_override")
+	pkgbuild.srcInfoTemplatePath = templatePath(env("srcinfo_template"), templateDir)
+	pkgbuild.installTemplatePath = env("install_template")
+	pkgbuild.PostInstall = strings.TrimRight(env("post_install"), "\n")
+	pkgbuild.PostUpgrade = strings.TrimRight(env("post_upgrade"), "\n")
+	pkgbuild.PreRemove = strings.TrimRight(env("pre_remove"), "\n")
+	if pkgbuild.installTemplatePath != "" || pkgbuild.PostInstall != "" || pkgbuild.PostUpgrade != "" || pkgbuild.PreRemove != "" {
+		pkgbuild.Install = pkgbuild.Pkgname + ".install"
 	}
-	pkgbuild.Source_x86_64 = strings.Split(os.Getenv("source_x86_64"), ",")
-	pkgbuild.Source_aarch64 = strings.Split(os.Getenv("source_aarch64"), ",")
+	pkgbuild.outputPath = env.get("output_path", "./output/")
+	pkgbuild.cacheDir = env("cache_dir")
+	pkgbuild.reportPath = env.get("report_path", "release-aur-report.json")
 
-	if len(pkgbuild.Source_aarch64) == 1 && pkgbuild.Source_aarch64[0] == "" {
-		pkgbuild.Source_aarch64 = []string{}
+	pkgbuild.aurURL = env("aur_url")
+	pkgbuild.aurRPCPath = env("aur_rpc_path")
+	pkgbuild.aurRPCVersion = env("aur_rpc_version")
+	pkgbuild.aurPlainURL = env("aur_plain_url")
+	pkgbuild.aurMirrorURL = env("aur_mirror")
+	if pkgbuild.aurMirrorURL == "github" {
+		pkgbuild.aurMirrorURL = githubAurMirror
 	}
+	pkgbuild.aurUsernames = env.list("aur_usernames")
+	pkgbuild.adoptOrphans = env.bool("adopt_orphans")
+	pkgbuild.pkgrelPolicy = parsePkgrelPolicy(env("pkgrel_policy"))
+	pkgbuild.idempotent = env.bool("idempotent")
+	pkgbuild.writeRemote = env.bool("write_remote")
 
-	pkgbuild.pkgbuildTemplatePath = getenv("pkgbuild_template", "./pkgbuild.tmpl")
-	pkgbuild.srcInfoTemplatePath = getenv("srcinfo_template", "./srcinfo.tmpl")
-	pkgbuild.outputPath = getenv("output_path", "./output/")
+	pkgbuild.verifyChecksums = env.bool("verify_checksums")
+	pkgbuild.checksumsURLs = env.list("checksums_url")
+	if len(pkgbuild.checksumsURLs) != 0 {
+		pkgbuild.checksumCalculator = parser.NewChecksumsFileCalculator(pkgbuild.checksumsURLs, pkgbuild.verifyChecksums)
+	}
 	return pkgbuild
 }
 
-func getenv(key, fallback string) string {
-	value := os.Getenv(key)
+// config looks up a setting by the name of its environment variable, an
+// empty value means it is not set.
+type config func(key string) string
+
+func (env config) get(key, fallback string) string {
+	value := env(key)
 	if len(value) == 0 {
 		return fallback
 	}
 	return value
 }
 
-func (pkgbuild *PkgBuild) generate() (string, error) {
-	slog.Info("starting pkgbuild.generate ..")
+func (env config) bool(key string) bool {
+	value, err := strconv.ParseBool(env(key))
+	return err == nil && value
+}
+
+func (env config) list(key string) []string {
+	value := env(key)
+	if len(value) == 0 {
+		return []string{}
+	}
+	return strings.Split(value, ",")
+}
+
+// environKeys are the names of the environment variables.
+func environKeys() []string {
+	keys := []string{}
+	for _, variable := range os.Environ() {
+		key, _, _ := strings.Cut(variable, "=")
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// extraVariables are the values of the extra_* keys that are set, named after
+// the rest of the key in CamelCase.
+func extraVariables(keys []string, env config) map[string]string {
+	extra := map[string]string{}
+	for _, key := range keys {
+		name, ok := strings.CutPrefix(key, "extra_")
+		if !ok || name == "" || env(key) == "" {
+			continue
+		}
+		parts := strings.Split(name, "_")
+		for i, part := range parts {
+			if part != "" {
+				parts[i] = strings.ToUpper(part[:1]) + part[1:]
+			}
+		}
+		extra[strings.Join(parts, "")] = env(key)
+	}
+	return extra
+}
+
+func (pkgbuild PkgBuild) newClient() Client {
+	return NewClient(time.Second*30, time.Second*5, 5).
+		WithCache(pkgbuild.cacheDir).
+		WithAur(pkgbuild.aurURL, pkgbuild.aurRPCPath, pkgbuild.aurRPCVersion, pkgbuild.aurPlainURL, pkgbuild.aurMirrorURL)
+}
+
+func (pkgbuild *PkgBuild) generate(ctx context.Context) (string, error) {
+	return pkgbuild.generateWith(ctx, pkgbuild.newClient())
+}
+
+// generateWith generates the package using client for every download and AUR
+// request, so a batch can share one client between its packages.
+func (pkgbuild *PkgBuild) generateWith(ctx context.Context, client Client) (string, error) {
+	slog.Info("starting pkgbuild.generate ..", "pkgname", pkgbuild.Pkgname)
+
+	if pkgbuild.Mode == "source" {
+		if err := pkgbuild.applyBuildPreset(); err != nil {
+			return "", err
+		}
+	}
+
+	if err := pkgbuild.normalizeLicences(); err != nil {
+		return "", err
+	}
+
+	download := func(url string) ([]byte, error) {
+		return client.Get(ctx, url)
+	}
+	if pkgbuild.releaseManifest != "" {
+		if err := pkgbuild.discoverReleaseAssets(download); err != nil {
+			return "", err
+		}
+	}
+
+	if pkgbuild.signedSources != "" {
+		if err := pkgbuild.pairSignatures(); err != nil {
+			return "", err
+		}
+	}
+
+	// sources are downloaded once even when they are both verified and checksummed
+	get := memoizeGet(download)
+	if pkgbuild.pgpKeyring != "" {
+		if err := pkgbuild.verifySignatures(get); err != nil {
+			return "", err
+		}
+	}
+	if pkgbuild.sigstoreTrustedRoot != "" {
+		if err := pkgbuild.verifySourceAttestations(get); err != nil {
+			return "", err
+		}
+	}
 
-	client := NewClient(time.Second*30, time.Second*5, 5)
 	var err error
-	if pkgbuild.Checksum_x86_64, err = pkgbuild.checksumCalculator(client.Get, pkgbuild.Source_x86_64); err != nil {
+	if pkgbuild.Checksum, err = pkgbuild.checksumCalculator(get, client.Checksum, pkgbuild.Source); err != nil {
+		return "", err
+	}
+
+	if pkgbuild.Checksum_x86_64, err = pkgbuild.checksumCalculator(get, client.Checksum, pkgbuild.Source_x86_64); err != nil {
+		return "", err
+	}
+
+	if pkgbuild.Checksum_aarch64, err = pkgbuild.checksumCalculator(get, client.Checksum, pkgbuild.Source_aarch64); err != nil {
 		return "", err
 	}
 
-	if pkgbuild.Checksum_aarch64, err = pkgbuild.checksumCalculator(client.Get, pkgbuild.Source_aarch64); err != nil {
+	if err := pkgbuild.addLocalSources(); err != nil {
+		return "", err
+	}
+	if err := pkgbuild.readChangelog(); err != nil {
 		return "", err
 	}
 
@@ -111,14 +360,25 @@ func (pkgbuild *PkgBuild) generate() (string, error) {
 		slog.Error("Failed to template PKGBUILD dumping\n ", "dump", pkgbuild)
 		return "", err
 	}
+	if pkgbuild.installScript, err = pkgbuild.templateInstall(); err != nil {
+		return "", err
+	}
+	pkgbuild.checkCustomLicences(PKGBUILD)
 
-	remotePkgrel, err := pkgbuild.comparator(client, *pkgbuild, PKGBUILD)
+	remote, err := pkgbuild.comparator(ctx, client, *pkgbuild, PKGBUILD)
+	if err != nil {
+		return "", err
+	}
+	if remote.decision == decisionUpToDate {
+		return pkgbuild.finishUpToDate(ctx, client, remote)
+	}
+	pkgrel, err := pkgbuild.pkgrelPolicy.decide(remote)
 	if err != nil {
 		return "", err
 	}
-	if remotePkgrel != -1 {
-		pkgbuild.Pkgrel = remotePkgrel + 1
-		slog.Info("New PKGBUILD file increasing pkgrel number to", "pkgrel", pkgbuild.Pkgrel)
+	if pkgrel != pkgbuild.Pkgrel {
+		pkgbuild.Pkgrel = pkgrel
+		slog.Info("Changing pkgrel number to", "pkgrel", pkgbuild.Pkgrel, "decision", remote.decision)
 
 		slog.Info("Templating again")
 		PKGBUILD, SRCINFO, err = pkgbuild.template()
@@ -126,22 +386,75 @@ func (pkgbuild *PkgBuild) generate() (string, error) {
 			slog.Error("Failed to template PKGBUILD dumping\n ", "dump", pkgbuild)
 			return "", err
 		}
+		if pkgbuild.installScript, err = pkgbuild.templateInstall(); err != nil {
+			return "", err
+		}
 	}
-	if err := writeFile(pkgbuild.outputPath+"PKGBUILD", PKGBUILD); err != nil {
+	files := reportFiles{PKGBUILD: pkgbuild.outputPath + "PKGBUILD", SRCINFO: pkgbuild.outputPath + ".SRCINFO"}
+	if err := writeFile(files.PKGBUILD, PKGBUILD); err != nil {
 		return "", err
 	}
 	slog.Info("Wrote PKGBUILD")
 
-	if err := writeFile(pkgbuild.outputPath+".SRCINFO", SRCINFO); err != nil {
+	if err := writeFile(files.SRCINFO, SRCINFO); err != nil {
 		return "", err
 	}
 
 	slog.Info("Wrote .SRCINFO")
 
+	if err := pkgbuild.writeCompanionFiles(&files); err != nil {
+		return "", err
+	}
+
+	pkgbuild.report = pkgbuild.newReport(remote, PKGBUILD, files)
+	if pkgbuild.reportPath != "" {
+		if err := writeReport(pkgbuild.reportPath, pkgbuild.report); err != nil {
+			return "", err
+		}
+		slog.Info("Wrote report", "path", pkgbuild.reportPath)
+	}
+
 	slog.Info("finished pkgbuild.generate ..")
 	return PKGBUILD, nil
 }
 
+// finishUpToDate ends an idempotent run whose PKGBUILD is already published,
+// nothing new is written but the published files when writeRemote is set.
+func (pkgbuild *PkgBuild) finishUpToDate(ctx context.Context, client Client, remote remoteState) (string, error) {
+	slog.Info("PKGBUILD already published to AUR, nothing to do")
+	pkgbuild.Pkgrel = remote.pkgrel
+
+	var files reportFiles
+	if pkgbuild.writeRemote {
+		SRCINFO, err := client.fetchAurFile(ctx, pkgbuild.Pkgname, ".SRCINFO")
+		if err != nil {
+			slog.Error("Failed to fetch .SRCINFO from AUR")
+			return "", err
+		}
+		files = reportFiles{PKGBUILD: pkgbuild.outputPath + "PKGBUILD", SRCINFO: pkgbuild.outputPath + ".SRCINFO"}
+		if err := writeFile(files.PKGBUILD, remote.pkgbuild); err != nil {
+			return "", err
+		}
+		if err := writeFile(files.SRCINFO, string(SRCINFO)); err != nil {
+			return "", err
+		}
+		// the comparator found the published install file, changelog and local sources to be the same
+		if err := pkgbuild.writeCompanionFiles(&files); err != nil {
+			return "", err
+		}
+		slog.Info("Wrote the published PKGBUILD and .SRCINFO")
+	}
+
+	pkgbuild.report = pkgbuild.newReport(remote, remote.pkgbuild, files)
+	if pkgbuild.reportPath != "" {
+		if err := writeReport(pkgbuild.reportPath, pkgbuild.report); err != nil {
+			return "", err
+		}
+		slog.Info("Wrote report", "path", pkgbuild.reportPath)
+	}
+	return remote.pkgbuild, nil
+}
+
 func writeFile(filePath string, content string) error {
 	outputDir := filepath.Dir(filePath)
 
@@ -164,31 +477,43 @@ func writeFile(filePath string, content string) error {
 
 func (pkgbuild PkgBuild) template() (string, string, error) {
 	slog.Info("Templating ...")
-	tmpl := template.New("pkgbuild").Funcs(template.FuncMap{
-		"join_quoted": func(items []string, sep string) string {
-			quoted := make([]string, len(items))
-			for i, item := range items {
-				quoted[i] = "'" + item + "'"
-			}
-			return strings.Join(quoted, sep)
-		},
-	})
-	tmpl, err := tmpl.ParseFiles(pkgbuild.pkgbuildTemplatePath, pkgbuild.srcInfoTemplatePath)
+	// an unset .Extra value is empty instead of "<no value>", extra values are optional
+	tmpl := template.New("pkgbuild").Funcs(templateFuncs()).Option("missingkey=zero")
+	tmpl, templateName, err := pkgbuild.parsePkgbuildTemplate(tmpl)
+	if err != nil {
+		return "", "", err
+	}
+	tmpl, srcinfoTemplateName, err := pkgbuild.parseSrcinfoTemplate(tmpl)
 	if err != nil {
 		return "", "", err
 	}
 
 	var pkgbuildBuf bytes.Buffer
-	templateName := filepath.Base(pkgbuild.pkgbuildTemplatePath)
 	if err := tmpl.ExecuteTemplate(&pkgbuildBuf, templateName, pkgbuild); err != nil {
 		return "", "", err
 	}
 
 	var srcinfoBuf bytes.Buffer
-	templateName = filepath.Base(pkgbuild.srcInfoTemplatePath)
-	if err := tmpl.ExecuteTemplate(&srcinfoBuf, templateName, pkgbuild); err != nil {
+	if err := tmpl.ExecuteTemplate(&srcinfoBuf, srcinfoTemplateName, pkgbuild); err != nil {
 		return "", "", err
 	}
 
 	return pkgbuildBuf.String(), srcinfoBuf.String(), nil
 }
+
+// templateInstall renders the Install file, it is empty when the package has none.
+func (pkgbuild PkgBuild) templateInstall() (string, error) {
+	if pkgbuild.Install == "" {
+		return "", nil
+	}
+	tmpl := template.New("install").Funcs(templateFuncs()).Option("missingkey=zero")
+	tmpl, name, err := pkgbuild.parseInstallTemplate(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, pkgbuild); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
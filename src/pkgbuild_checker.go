@@ -1,109 +1,359 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"path/filepath"
 	"slices"
+	"strings"
+	"time"
 
 	"github.com/fuad-daoud/release-aur/src/parser"
 )
 
+// makepkgOptions are the options a PKGBUILD can enable, or disable with a leading "!".
+var makepkgOptions = []string{"autodeps", "buildflags", "ccache", "debug", "distcc", "docs", "emptydirs", "libtool", "lto", "makeflags", "purge", "staticlibs", "strip", "zipman"}
+
 func validate(p PkgBuild) error {
 	if p.CliName == "" {
-		return fmt.Errorf("CliName is required")
+		return invalid("CliName", "CliName is required")
 	}
 	if len(p.Maintainers) == 0 {
-		return fmt.Errorf("At least one Maintainer is required")
+		return invalid("Maintainers", "At least one Maintainer is required")
 	}
 	if p.Pkgname == "" {
-		return fmt.Errorf("Pkgname is required")
+		return invalid("Pkgname", "Pkgname is required")
 	}
 	if p.Version == "" {
-		return fmt.Errorf("Version is required")
+		return invalid("Version", "Version is required")
 	}
 	if p.Description == "" {
-		return fmt.Errorf("Description is required")
+		return invalid("Description", "Description is required")
 	}
 	if p.Url == "" {
-		return fmt.Errorf("Url is required")
+		return invalid("Url", "Url is required")
 	}
 	if len(p.Arch) == 0 {
-		return fmt.Errorf("At least one Arch is required")
+		return invalid("Arch", "At least one Arch is required")
 	}
 	if len(p.Licence) == 0 {
-		return fmt.Errorf("At least one Licence is required")
+		return invalid("Licence", "At least one Licence is required")
 	}
-	if len(p.Source_x86_64) == 0 {
-		return fmt.Errorf("Source_x86_64 is required")
+	for _, licence := range p.Licence {
+		if _, _, err := normalizeLicence(licence); err != nil {
+			return invalid("Licence", "Invalid Licence %q: %v", licence, err)
+		}
 	}
-	return nil
+	for _, fingerprint := range p.Validpgpkeys {
+		if !fingerprintPattern.MatchString(fingerprint) {
+			return invalid("Validpgpkeys", "Validpgpkeys must be full 40 character fingerprints, got %q", fingerprint)
+		}
+	}
+	if (p.sigstoreBundleSuffix != "" || p.slsaProvenance != "") && p.sigstoreTrustedRoot == "" {
+		return invalid("sigstoreTrustedRoot", "A sigstore trusted root is required to verify sigstore bundles and SLSA provenance")
+	}
+	if p.sigstoreTrustedRoot != "" && p.sigstoreBundleSuffix == "" && p.slsaProvenance == "" {
+		return invalid("sigstoreTrustedRoot", "A sigstore trusted root needs a sigstore bundle suffix or SLSA provenance to verify")
+	}
+	// any Fulcio certificate chains to the trusted root, only the identity tells who signed
+	if p.sigstoreTrustedRoot != "" && (p.sigstoreIdentity == "" || p.sigstoreIssuer == "") {
+		return invalid("sigstoreIdentity", "A sigstore identity and issuer are required to verify sigstore bundles and SLSA provenance")
+	}
+	for _, option := range p.Options {
+		if !slices.Contains(makepkgOptions, strings.TrimPrefix(option, "!")) {
+			return invalid("Options", "Unknown Option %q, expected one of: %s, optionally prefixed with !", option, strings.Join(makepkgOptions, ", "))
+		}
+	}
+	for _, backup := range p.Backup {
+		if backup == "" || strings.HasPrefix(backup, "/") {
+			return invalid("Backup", "Backup files must be relative to the package root without a leading /, got %q", backup)
+		}
+	}
+	// the changelog and local sources are written next to the PKGBUILD by their filename
+	written := []string{"PKGBUILD", ".SRCINFO", p.Install}
+	if p.Changelog != "" && slices.Contains(written, p.Changelog) {
+		return invalid("Changelog", "Changelog %s clashes with another file next to the PKGBUILD", p.Changelog)
+	}
+	written = append(written, p.Changelog)
+	// makepkg keeps local and downloaded sources side by side in the same directory
+	downloaded := []string{}
+	for _, source := range slices.Concat(p.Source, p.Source_x86_64, p.Source_aarch64) {
+		downloaded = append(downloaded, sourceFilename(source))
+	}
+	for _, path := range p.localSources {
+		name := filepath.Base(path)
+		if slices.Contains(written, name) {
+			return invalid("localSources", "Local source %s clashes with another file next to the PKGBUILD", name)
+		}
+		if slices.Contains(downloaded, name) {
+			return invalid("localSources", "Local source %s clashes with the filename of a downloaded source", name)
+		}
+		written = append(written, name)
+	}
+	switch p.Mode {
+	case "", "bin":
+		if len(p.Source_x86_64) == 0 && p.releaseManifest == "" {
+			return invalid("Source_x86_64", "Source_x86_64 is required")
+		}
+	case "source":
+		if len(p.Source) == 0 {
+			return invalid("Source", "Source is required in source mode")
+		}
+		if _, ok := buildPresets[p.BuildPreset]; !ok {
+			return invalid("BuildPreset", "Unknown BuildPreset %q, expected one of: %s", p.BuildPreset, buildPresetNames())
+		}
+	default:
+		return invalid("Mode", "Unknown Mode %q, expected bin or source", p.Mode)
+	}
+	if err := validateTemplateName(p.templateName); err != nil {
+		return err
+	}
+	return p.pkgrelPolicy.validate()
+}
+
+const (
+	decisionNewPackage = "new-package"
+	decisionNewVersion = "new-version"
+	decisionPkgrelBump = "pkgrel-bump"
+	// decisionUpToDate is only reached in idempotent mode, the AUR already
+	// has the same PKGBUILD
+	decisionUpToDate = "up-to-date"
+)
+
+// remoteState is what the comparator found on the AUR, version and pkgrel are
+// the published ones and empty for a new package. pkgbuild is the published
+// PKGBUILD when it was fetched.
+type remoteState struct {
+	decision string
+	version  string
+	pkgrel   string
+	pkgbuild string
+	warnings []string
 }
 
-type compareWithRemote func(client Client, pkgbuild PkgBuild, PKGBUILD string) (int, error)
+type compareWithRemote func(ctx context.Context, client Client, pkgbuild PkgBuild, PKGBUILD string) (remoteState, error)
 
-func defaultCompareWithRemote(client Client, pkgbuild PkgBuild, PKGBUILD string) (int, error) {
+func defaultCompareWithRemote(ctx context.Context, client Client, pkgbuild PkgBuild, PKGBUILD string) (remoteState, error) {
 
-	data, err := client.getAurPackageVersions(pkgbuild.Pkgname)
+	data, err := client.getAurPackageVersions(ctx, pkgbuild.Pkgname)
 
 	if err != nil {
 		slog.Error("Failed to fetch package info from AUR")
-		return -1, err
+		return remoteState{}, err
+	}
+	warnings := remoteWarnings(pkgbuild, data.info)
+	for _, warning := range warnings {
+		slog.Warn(warning)
+	}
+	if !data.new {
+		if err := checkOwnership(pkgbuild, data.info); err != nil {
+			return remoteState{}, err
+		}
+		if data.version != pkgbuild.Version && vercmp(pkgbuild.Version, data.version) < 0 {
+			return remoteState{}, fmt.Errorf("%w: %s is older than %s on the AUR", ErrDowngrade, pkgbuild.Version, data.version)
+		}
 	}
 
 	if data.new == false && data.version == pkgbuild.Version {
 		slog.Warn("AUR version and current version match, this should only be a PKGBUILD update")
 		slog.Info("Comparing PKGBUILD to validate")
-		aurPKGBUILD, err := client.fetchPKGBUILD(pkgbuild.Pkgname)
+		aurPKGBUILD, err := client.fetchPKGBUILD(ctx, pkgbuild.Pkgname)
 		if err != nil {
 			slog.Error("Failed to fetch PKGBUILD from AUR")
-			return -1, err
+			return remoteState{}, err
 		}
-		if parser.ComparePKGBUILDs(PKGBUILD, aurPKGBUILD) {
-			slog.Error("Files match!! should not publish to the AUR without changes to PKGBUILD file or the software Version")
-			return -1, fmt.Errorf("PKGBUILD already published to AUR")
-		}
-
 		remoteChecksums, err := parser.ExtractChecksums(aurPKGBUILD)
 
 		if err != nil {
 			slog.Error("Failed extact remote checksums")
-			return -1, err
+			return remoteState{}, err
 		}
-
-		fmt.Printf("remoteChecksums: %v\n", remoteChecksums)
-		if len(remoteChecksums["x86_64"]) != 0 && remoteChecksums["x86_64"][0] != "SKIP" {
-
-			if len(pkgbuild.Checksum_x86_64) != len(remoteChecksums["x86_64"]) {
-				slog.Error("Checksums differ!! should not increament the pkgrel with new sources")
-				return -1, fmt.Errorf("different number of x86_64 checksums")
-			}
-
-			for _, local := range pkgbuild.Checksum_x86_64 {
-				found := slices.Contains(remoteChecksums["x86_64"], local)
-				if !found {
-					slog.Error("Checksums differ!! should not increament the pkgrel with new sources")
-					return -1, fmt.Errorf("different x86_64 checksums")
-				}
+		// a PKGBUILD is compared without its checksums, but a changed local source is a change of the package
+		downloadedChecksums, localChecksums := pkgbuild.splitLocalChecksums()
+		same := parser.ComparePKGBUILDs(PKGBUILD, aurPKGBUILD) && containsAll(remoteChecksums[""], localChecksums)
+		if same {
+			if same, err = sameFiles(ctx, client, pkgbuild); err != nil {
+				return remoteState{}, err
 			}
 		}
-		if len(remoteChecksums["aarch64"]) != 0 && remoteChecksums["aarch64"][0] != "SKIP" {
-			if len(pkgbuild.Checksum_aarch64) != len(remoteChecksums["aarch64"]) {
-				slog.Error("Checksums differ!! should not increament the pkgrel with new sources")
-				return -1, fmt.Errorf("different number of aarch64 checksums")
-			}
-			for _, local := range pkgbuild.Checksum_aarch64 {
-				found := slices.Contains(remoteChecksums["aarch64"], local)
-				if !found {
-					slog.Error("Checksums differ!! should not increament the pkgrel with new sources")
-					return -1, fmt.Errorf("different aarch64 checksums")
-				}
+		// the comparison ignores checksums, a re-uploaded release asset is not up to date
+		fmt.Printf("remoteChecksums: %v\n", remoteChecksums)
+		remoteSources, err := parser.ExtractSources(aurPKGBUILD)
+		if err != nil {
+			return remoteState{}, err
+		}
+		// the published local sources come last as well, they may have been added or dropped since
+		remoteDownloaded := remoteChecksums[""]
+		remoteDownloaded = remoteDownloaded[:max(len(remoteDownloaded)-countLocalSources(remoteSources[""]), 0)]
+		if err := compareChecksums("", downloadedChecksums, remoteDownloaded); err != nil {
+			return remoteState{}, err
+		}
+		if err := compareChecksums("x86_64", pkgbuild.Checksum_x86_64, remoteChecksums["x86_64"]); err != nil {
+			return remoteState{}, err
+		}
+		if err := compareChecksums("aarch64", pkgbuild.Checksum_aarch64, remoteChecksums["aarch64"]); err != nil {
+			return remoteState{}, err
+		}
+
+		// always-bump rebuilds an unchanged PKGBUILD, e.g. against updated dependencies
+		if same && !pkgbuild.pkgrelPolicy.bumpsPublished() {
+			if pkgbuild.idempotent {
+				slog.Info("Files match, the AUR is already up to date")
+				return remoteState{decision: decisionUpToDate, version: data.version, pkgrel: data.pkgrel, pkgbuild: aurPKGBUILD, warnings: warnings}, nil
 			}
+			slog.Error("Files match!! should not publish to the AUR without changes to PKGBUILD file or the software Version")
+			return remoteState{}, ErrAlreadyPublished
 		}
 
-		return data.pkgrel, nil
+		return remoteState{decision: decisionPkgrelBump, version: data.version, pkgrel: data.pkgrel, pkgbuild: aurPKGBUILD, warnings: warnings}, nil
 	}
 	if data.new {
 		slog.Info("New package")
+		return remoteState{decision: decisionNewPackage, warnings: warnings}, nil
 	}
-	return -1, nil
+	return remoteState{decision: decisionNewVersion, version: data.version, pkgrel: data.pkgrel, warnings: warnings}, nil
+}
+
+// sameFiles reports whether the AUR has the same install file and changelog as
+// pkgbuild, which a PKGBUILD only names.
+func sameFiles(ctx context.Context, client Client, pkgbuild PkgBuild) (bool, error) {
+	for _, file := range []struct{ name, content string }{
+		{pkgbuild.Install, pkgbuild.installScript},
+		{pkgbuild.Changelog, pkgbuild.changelog},
+	} {
+		if file.name == "" {
+			continue
+		}
+		remote, err := client.fetchAurFile(ctx, pkgbuild.Pkgname, file.name)
+		if err != nil {
+			slog.Error("Failed to fetch file from AUR", "file", file.name)
+			return false, err
+		}
+		if strings.TrimSpace(string(remote)) != strings.TrimSpace(file.content) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func containsAll(items, wanted []string) bool {
+	for _, item := range wanted {
+		if !slices.Contains(items, item) {
+			return false
+		}
+	}
+	return true
+}
+
+func compareChecksums(arch string, local, remote []string) error {
+	if len(remote) == 0 || remote[0] == "SKIP" {
+		return nil
+	}
+	label := "checksums"
+	if arch != "" {
+		label = arch + " checksums"
+	}
+
+	if len(local) != len(remote) {
+		slog.Error("Checksums differ!! should not increament the pkgrel with new sources")
+		return fmt.Errorf("different number of %s: %w", label, ErrChecksumMismatch)
+	}
+	for _, checksum := range local {
+		if !slices.Contains(remote, checksum) {
+			slog.Error("Checksums differ!! should not increament the pkgrel with new sources")
+			return fmt.Errorf("different %s: %w", label, ErrChecksumMismatch)
+		}
+	}
+	return nil
+}
+
+// remoteWarnings lists what about the published package looks off compared to
+// what is about to be published, none of it stops the release.
+func remoteWarnings(pkgbuild PkgBuild, info AurPackage) []string {
+	if info.Name == "" {
+		return nil
+	}
+	var warnings []string
+	// with aur usernames configured checkOwnership takes care of the maintainer
+	if len(pkgbuild.aurUsernames) == 0 {
+		if info.Maintainer == "" {
+			warnings = append(warnings, fmt.Sprintf("%s is orphaned on the AUR", info.Name))
+		} else if !isOwnAccount(pkgbuild, info.Maintainer) {
+			warnings = append(warnings, fmt.Sprintf("%s is maintained by %s on the AUR", info.Name, info.Maintainer))
+		}
+	}
+	if info.OutOfDate != 0 {
+		flagged := time.Unix(info.OutOfDate, 0).UTC().Format(time.DateOnly)
+		warnings = append(warnings, fmt.Sprintf("%s is flagged out of date on the AUR since %s", info.Name, flagged))
+	}
+	if info.PackageBase != "" && info.PackageBase != pkgbuild.Pkgname {
+		warnings = append(warnings, fmt.Sprintf("%s belongs to the package base %s on the AUR", info.Name, info.PackageBase))
+	}
+	if !sameElements(info.Depends, pkgbuild.Depends) {
+		warnings = append(warnings, fmt.Sprintf("depends change from %v on the AUR to %v", info.Depends, pkgbuild.Depends))
+	}
+	if !sameElements(info.License, pkgbuild.Licence) {
+		warnings = append(warnings, fmt.Sprintf("licenses change from %v on the AUR to %v", info.License, pkgbuild.Licence))
+	}
+	return warnings
+}
+
+// checkOwnership refuses to publish an existing package unless one of the
+// configured aur usernames maintains or co-maintains it. Orphans are only
+// published in adopt mode, the package has to be adopted before pushing.
+// Without the AUR RPC the maintainer is unknown and publishing is refused.
+// countLocalSources counts the sources shipped next to the PKGBUILD, the ones
+// without a URL.
+func countLocalSources(sources []string) int {
+	count := 0
+	for _, source := range sources {
+		if _, url := parser.SplitSource(source); !strings.Contains(url, "://") {
+			count++
+		}
+	}
+	return count
+}
+
+func checkOwnership(pkgbuild PkgBuild, info AurPackage) error {
+	if len(pkgbuild.aurUsernames) == 0 {
+		return nil
+	}
+	if info.Name == "" {
+		return fmt.Errorf("%w: cannot check the maintainer of %s without the AUR RPC", ErrRemoteUnavailable, pkgbuild.Pkgname)
+	}
+	if info.Maintainer == "" {
+		if pkgbuild.adoptOrphans {
+			slog.Warn("Package is orphaned on the AUR, adopt it before pushing", "pkgname", info.Name)
+			return nil
+		}
+		return fmt.Errorf("%w: %s is orphaned on the AUR, adopt it or enable adopt orphans to publish it", ErrNotMaintainer, info.Name)
+	}
+	for _, account := range slices.Concat([]string{info.Maintainer}, info.CoMaintainers) {
+		for _, username := range pkgbuild.aurUsernames {
+			if strings.EqualFold(account, username) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: %s is maintained by %s on the AUR, not by any of %v", ErrNotMaintainer, info.Name, info.Maintainer, pkgbuild.aurUsernames)
+}
+
+// isOwnAccount reports whether the AUR account name shows up in one of the
+// maintainer or contributor lines.
+func isOwnAccount(pkgbuild PkgBuild, account string) bool {
+	account = strings.ToLower(account)
+	for _, person := range slices.Concat(pkgbuild.Maintainers, pkgbuild.Contributors) {
+		if strings.Contains(strings.ToLower(person), account) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameElements(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
 }
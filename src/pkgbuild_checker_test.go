@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -164,6 +167,285 @@ func TestValidate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "Source_x86_64 is required",
 		},
+		{
+			name: "valid source package",
+			pkg: PkgBuild{
+				CliName:     "test",
+				Maintainers: []string{"Test User"},
+				Pkgname:     "test",
+				Version:     "1.0.0",
+				Description: "Test package",
+				Url:         "https://example.com",
+				Arch:        []string{"x86_64"},
+				Licence:     []string{"MIT"},
+				Mode:        "source",
+				BuildPreset: "go",
+				Source:      []string{"https://example.com/test-1.0.0.tar.gz"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "missing Source in source mode",
+			pkg: PkgBuild{
+				CliName:     "test",
+				Maintainers: []string{"Test User"},
+				Pkgname:     "test",
+				Version:     "1.0.0",
+				Description: "Test package",
+				Url:         "https://example.com",
+				Arch:        []string{"x86_64"},
+				Licence:     []string{"MIT"},
+				Mode:        "source",
+				BuildPreset: "go",
+			},
+			wantErr: true,
+			errMsg:  "Source is required in source mode",
+		},
+		{
+			name: "unknown BuildPreset",
+			pkg: PkgBuild{
+				CliName:     "test",
+				Maintainers: []string{"Test User"},
+				Pkgname:     "test",
+				Version:     "1.0.0",
+				Description: "Test package",
+				Url:         "https://example.com",
+				Arch:        []string{"x86_64"},
+				Licence:     []string{"MIT"},
+				Mode:        "source",
+				BuildPreset: "zig",
+				Source:      []string{"https://example.com/test-1.0.0.tar.gz"},
+			},
+			wantErr: true,
+			errMsg:  `Unknown BuildPreset "zig", expected one of: go, make, rust`,
+		},
+		{
+			name: "short validpgpkeys fingerprint",
+			pkg: PkgBuild{
+				CliName:       "test",
+				Maintainers:   []string{"Test User"},
+				Pkgname:       "test-bin",
+				Version:       "1.0.0",
+				Description:   "Test package",
+				Url:           "https://example.com",
+				Arch:          []string{"x86_64"},
+				Licence:       []string{"MIT"},
+				Source_x86_64: []string{"https://example.com/test"},
+				Validpgpkeys:  []string{"ABCDEF0123456789"},
+			},
+			wantErr: true,
+			errMsg:  `Validpgpkeys must be full 40 character fingerprints, got "ABCDEF0123456789"`,
+		},
+		{
+			name: "custom licence",
+			pkg: PkgBuild{
+				CliName:       "test",
+				Maintainers:   []string{"Test User"},
+				Pkgname:       "test-bin",
+				Version:       "1.0.0",
+				Description:   "Test package",
+				Url:           "https://example.com",
+				Arch:          []string{"x86_64"},
+				Licence:       []string{"MIT", "custom:Proprietary"},
+				Source_x86_64: []string{"https://example.com/test"},
+			},
+			wantErr: true,
+			errMsg:  `Invalid Licence "custom:Proprietary": "custom:Proprietary" is not an SPDX identifier, name a custom license LicenseRef-<name> and install its license file in package()`,
+		},
+		{
+			name: "provenance without trusted root",
+			pkg: PkgBuild{
+				CliName:        "test",
+				Maintainers:    []string{"Test User"},
+				Pkgname:        "test-bin",
+				Version:        "1.0.0",
+				Description:    "Test package",
+				Url:            "https://example.com",
+				Arch:           []string{"x86_64"},
+				Licence:        []string{"MIT"},
+				Source_x86_64:  []string{"https://example.com/test"},
+				slsaProvenance: "https://example.com/test.intoto.jsonl",
+			},
+			wantErr: true,
+			errMsg:  "A sigstore trusted root is required to verify sigstore bundles and SLSA provenance",
+		},
+		{
+			name: "trusted root without bundles or provenance",
+			pkg: PkgBuild{
+				CliName:             "test",
+				Maintainers:         []string{"Test User"},
+				Pkgname:             "test-bin",
+				Version:             "1.0.0",
+				Description:         "Test package",
+				Url:                 "https://example.com",
+				Arch:                []string{"x86_64"},
+				Licence:             []string{"MIT"},
+				Source_x86_64:       []string{"https://example.com/test"},
+				sigstoreTrustedRoot: "trusted_root.json",
+				sigstoreIdentity:    "^https://github.com/fuad-daoud/",
+				sigstoreIssuer:      "https://token.actions.githubusercontent.com",
+			},
+			wantErr: true,
+			errMsg:  "A sigstore trusted root needs a sigstore bundle suffix or SLSA provenance to verify",
+		},
+		{
+			name: "trusted root without identity",
+			pkg: PkgBuild{
+				CliName:              "test",
+				Maintainers:          []string{"Test User"},
+				Pkgname:              "test-bin",
+				Version:              "1.0.0",
+				Description:          "Test package",
+				Url:                  "https://example.com",
+				Arch:                 []string{"x86_64"},
+				Licence:              []string{"MIT"},
+				Source_x86_64:        []string{"https://example.com/test"},
+				sigstoreTrustedRoot:  "trusted_root.json",
+				sigstoreBundleSuffix: ".sigstore.json",
+				sigstoreIssuer:       "https://token.actions.githubusercontent.com",
+			},
+			wantErr: true,
+			errMsg:  "A sigstore identity and issuer are required to verify sigstore bundles and SLSA provenance",
+		},
+		{
+			name: "unknown Mode",
+			pkg: PkgBuild{
+				CliName:       "test",
+				Maintainers:   []string{"Test User"},
+				Pkgname:       "test",
+				Version:       "1.0.0",
+				Description:   "Test package",
+				Url:           "https://example.com",
+				Arch:          []string{"x86_64"},
+				Licence:       []string{"MIT"},
+				Mode:          "appimage",
+				Source_x86_64: []string{"https://example.com/test"},
+			},
+			wantErr: true,
+			errMsg:  `Unknown Mode "appimage", expected bin or source`,
+		},
+		{
+			name: "unknown option",
+			pkg: PkgBuild{
+				CliName:       "test",
+				Maintainers:   []string{"Test User"},
+				Pkgname:       "test-bin",
+				Version:       "1.0.0",
+				Description:   "Test package",
+				Url:           "https://example.com",
+				Arch:          []string{"x86_64"},
+				Licence:       []string{"MIT"},
+				Source_x86_64: []string{"https://example.com/test"},
+				Options:       []string{"!strip", "!stripped"},
+			},
+			wantErr: true,
+			errMsg:  `Unknown Option "!stripped", expected one of: autodeps, buildflags, ccache, debug, distcc, docs, emptydirs, libtool, lto, makeflags, purge, staticlibs, strip, zipman, optionally prefixed with !`,
+		},
+		{
+			name: "absolute backup",
+			pkg: PkgBuild{
+				CliName:       "test",
+				Maintainers:   []string{"Test User"},
+				Pkgname:       "test-bin",
+				Version:       "1.0.0",
+				Description:   "Test package",
+				Url:           "https://example.com",
+				Arch:          []string{"x86_64"},
+				Licence:       []string{"MIT"},
+				Source_x86_64: []string{"https://example.com/test"},
+				Backup:        []string{"etc/test/config.toml", "/etc/test/other.toml"},
+			},
+			wantErr: true,
+			errMsg:  `Backup files must be relative to the package root without a leading /, got "/etc/test/other.toml"`,
+		},
+		{
+			name: "changelog clash",
+			pkg: PkgBuild{
+				CliName:       "test",
+				Maintainers:   []string{"Test User"},
+				Pkgname:       "test-bin",
+				Version:       "1.0.0",
+				Description:   "Test package",
+				Url:           "https://example.com",
+				Arch:          []string{"x86_64"},
+				Licence:       []string{"MIT"},
+				Source_x86_64: []string{"https://example.com/test"},
+				Changelog:     "PKGBUILD",
+			},
+			wantErr: true,
+			errMsg:  "Changelog PKGBUILD clashes with another file next to the PKGBUILD",
+		},
+		{
+			name: "local sources clash",
+			pkg: PkgBuild{
+				CliName:       "test",
+				Maintainers:   []string{"Test User"},
+				Pkgname:       "test-bin",
+				Version:       "1.0.0",
+				Description:   "Test package",
+				Url:           "https://example.com",
+				Arch:          []string{"x86_64"},
+				Licence:       []string{"MIT"},
+				Source_x86_64: []string{"https://example.com/test"},
+				Install:       "test-bin.install",
+				localSources:  []string{"dist/test.service", "packaging/test-bin.install"},
+			},
+			wantErr: true,
+			errMsg:  "Local source test-bin.install clashes with another file next to the PKGBUILD",
+		},
+		{
+			name: "local sources with the same filename",
+			pkg: PkgBuild{
+				CliName:       "test",
+				Maintainers:   []string{"Test User"},
+				Pkgname:       "test-bin",
+				Version:       "1.0.0",
+				Description:   "Test package",
+				Url:           "https://example.com",
+				Arch:          []string{"x86_64"},
+				Licence:       []string{"MIT"},
+				Source_x86_64: []string{"https://example.com/test"},
+				localSources:  []string{"dist/test.service", "packaging/test.service"},
+			},
+			wantErr: true,
+			errMsg:  "Local source test.service clashes with another file next to the PKGBUILD",
+		},
+		{
+			name: "local source clashes with a downloaded source",
+			pkg: PkgBuild{
+				CliName:       "test",
+				Maintainers:   []string{"Test User"},
+				Pkgname:       "test-bin",
+				Version:       "1.0.0",
+				Description:   "Test package",
+				Url:           "https://example.com",
+				Arch:          []string{"x86_64"},
+				Licence:       []string{"MIT"},
+				Source:        []string{"https://example.com/LICENSE"},
+				Source_x86_64: []string{"test-1.0.0::https://example.com/test"},
+				localSources:  []string{"dist/test.service", "LICENSE"},
+			},
+			wantErr: true,
+			errMsg:  "Local source LICENSE clashes with the filename of a downloaded source",
+		},
+		{
+			name: "local source clashes with a renamed source",
+			pkg: PkgBuild{
+				CliName:        "test",
+				Maintainers:    []string{"Test User"},
+				Pkgname:        "test-bin",
+				Version:        "1.0.0",
+				Description:    "Test package",
+				Url:            "https://example.com",
+				Arch:           []string{"x86_64", "aarch64"},
+				Licence:        []string{"MIT"},
+				Source_x86_64:  []string{"https://example.com/test"},
+				Source_aarch64: []string{"test-1.0.0::https://example.com/test-arm64"},
+				localSources:   []string{"build/test-1.0.0"},
+			},
+			wantErr: true,
+			errMsg:  "Local source test-1.0.0 clashes with the filename of a downloaded source",
+		},
 		{
 			name: "optional fields can be empty",
 			pkg: PkgBuild{
@@ -228,7 +510,7 @@ func TestNewPkgBuildFromEnv(t *testing.T) {
 				CliName:              "test",
 				Pkgname:              "test-bin",
 				Version:              "1.0.0",
-				Pkgrel:               1,
+				Pkgrel:               "1",
 				Description:          "Test package",
 				Url:                  "https://example.com",
 				Arch:                 []string{"x86_64", "aarch64"},
@@ -265,7 +547,7 @@ func TestNewPkgBuildFromEnv(t *testing.T) {
 				CliName:              "test",
 				Pkgname:              "test-bin",
 				Version:              "1.0.0",
-				Pkgrel:               1,
+				Pkgrel:               "1",
 				Description:          "Test package",
 				Url:                  "https://example.com",
 				Arch:                 []string{"x86_64", "aarch64"},
@@ -275,7 +557,6 @@ func TestNewPkgBuildFromEnv(t *testing.T) {
 				Source_x86_64:        []string{"https://example.com/x86"},
 				Source_aarch64:       []string{"https://example.com/arm"},
 				pkgbuildTemplatePath: "./custom.tmpl",
-				srcInfoTemplatePath:  "./srcinfo.tmpl",
 			},
 		},
 		{
@@ -291,21 +572,56 @@ func TestNewPkgBuildFromEnv(t *testing.T) {
 				"source_x86_64": "https://example.com/x86",
 			},
 			expected: PkgBuild{
-				Maintainers:          []string{"User1 <user1@example.com>"},
-				Contributors:         []string{},
-				Pkgname:              "test-bin",
-				Version:              "1.0.0",
-				Pkgrel:               1,
-				Description:          "Test package",
-				Url:                  "https://example.com",
-				Arch:                 []string{"x86_64"},
-				Licence:              []string{"MIT"},
-				Provides:             []string{},
-				Conflicts:            []string{},
-				Source_x86_64:        []string{"https://example.com/x86"},
-				Source_aarch64:       []string{},
-				pkgbuildTemplatePath: "./pkgbuild.tmpl",
-				srcInfoTemplatePath:  "./srcinfo.tmpl",
+				Maintainers:    []string{"User1 <user1@example.com>"},
+				Contributors:   []string{},
+				Pkgname:        "test-bin",
+				Version:        "1.0.0",
+				Pkgrel:         "1",
+				Description:    "Test package",
+				Url:            "https://example.com",
+				Arch:           []string{"x86_64"},
+				Licence:        []string{"MIT"},
+				Provides:       []string{},
+				Conflicts:      []string{},
+				Source_x86_64:  []string{"https://example.com/x86"},
+				Source_aarch64: []string{},
+			},
+		},
+		{
+			name: "source mode",
+			envVars: map[string]string{
+				"maintainers":  "User1",
+				"pkgname":      "test",
+				"version":      "1.0.0",
+				"description":  "Test",
+				"url":          "https://example.com",
+				"arch":         "x86_64",
+				"licence":      "MIT",
+				"mode":         "source",
+				"build_preset": "go",
+				"depends":      "glibc",
+				"makedepends":  "git",
+				"source":       "test-1.0.0.tar.gz::https://example.com/v1.0.0.tar.gz",
+			},
+			expected: PkgBuild{
+				Maintainers:    []string{"User1"},
+				Contributors:   []string{},
+				Pkgname:        "test",
+				Version:        "1.0.0",
+				Pkgrel:         "1",
+				Description:    "Test",
+				Url:            "https://example.com",
+				Arch:           []string{"x86_64"},
+				Licence:        []string{"MIT"},
+				Depends:        []string{"glibc"},
+				Makedepends:    []string{"git"},
+				Provides:       []string{},
+				Conflicts:      []string{},
+				Source:         []string{"test-1.0.0.tar.gz::https://example.com/v1.0.0.tar.gz"},
+				Source_x86_64:  []string{},
+				Source_aarch64: []string{},
+				Mode:           "source",
+				BuildPreset:    "go",
 			},
 		},
 		{
@@ -321,21 +637,19 @@ func TestNewPkgBuildFromEnv(t *testing.T) {
 				"source_x86_64": "https://example.com/x86",
 			},
 			expected: PkgBuild{
-				Maintainers:          []string{"User1"},
-				Contributors:         []string{},
-				Pkgname:              "test",
-				Version:              "1.0.0",
-				Pkgrel:               1,
-				Description:          "Test",
-				Url:                  "https://example.com",
-				Arch:                 []string{"x86_64"},
-				Licence:              []string{"MIT"},
-				Provides:             []string{},
-				Conflicts:            []string{},
-				Source_x86_64:        []string{"https://example.com/x86"},
-				Source_aarch64:       []string{},
-				pkgbuildTemplatePath: "./pkgbuild.tmpl",
-				srcInfoTemplatePath:  "./srcinfo.tmpl",
+				Maintainers:    []string{"User1"},
+				Contributors:   []string{},
+				Pkgname:        "test",
+				Version:        "1.0.0",
+				Pkgrel:         "1",
+				Description:    "Test",
+				Url:            "https://example.com",
+				Arch:           []string{"x86_64"},
+				Licence:        []string{"MIT"},
+				Provides:       []string{},
+				Conflicts:      []string{},
+				Source_x86_64:  []string{"https://example.com/x86"},
+				Source_aarch64: []string{},
 			},
 		},
 	}
@@ -363,8 +677,261 @@ func TestNewPkgBuildFromEnv(t *testing.T) {
 			assert.Equal(t, tt.expected.Conflicts, result.Conflicts)
 			assert.Equal(t, tt.expected.Source_x86_64, result.Source_x86_64)
 			assert.Equal(t, tt.expected.Source_aarch64, result.Source_aarch64)
+			if tt.expected.Mode != "" {
+				assert.Equal(t, tt.expected.Source, result.Source)
+				assert.Equal(t, tt.expected.Mode, result.Mode)
+				assert.Equal(t, tt.expected.BuildPreset, result.BuildPreset)
+				assert.Equal(t, tt.expected.Depends, result.Depends)
+				assert.Equal(t, tt.expected.Makedepends, result.Makedepends)
+			}
 			assert.Equal(t, tt.expected.pkgbuildTemplatePath, result.pkgbuildTemplatePath)
 			assert.Equal(t, tt.expected.srcInfoTemplatePath, result.srcInfoTemplatePath)
 		})
 	}
 }
+
+func TestNewPkgBuildFromEnv_ChecksumsURL(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("checksums_url", "https://example.com/checksums.txt,https://example.com/SHA256SUMS")
+	os.Setenv("verify_checksums", "true")
+
+	result := NewPkgBuildFromEnv()
+
+	assert.Equal(t, []string{"https://example.com/checksums.txt", "https://example.com/SHA256SUMS"}, result.checksumsURLs)
+	assert.True(t, result.verifyChecksums)
+	assert.NotNil(t, result.checksumCalculator)
+}
+
+func TestNewPkgBuildFromEnv_Signatures(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("validpgpkeys", "abcd 1234 ABCD 1234 ABCD  1234 ABCD 1234 ABCD 1234")
+	os.Setenv("signed_sources", "*-x86_64")
+	os.Setenv("pgp_keyring", "keyring.gpg")
+
+	result := NewPkgBuildFromEnv()
+
+	assert.Equal(t, []string{"ABCD1234ABCD1234ABCD1234ABCD1234ABCD1234"}, result.Validpgpkeys)
+	assert.Equal(t, "*-x86_64", result.signedSources)
+	assert.Equal(t, ".sig", result.signatureSuffix)
+	assert.Equal(t, "keyring.gpg", result.pgpKeyring)
+}
+
+func TestNewPkgBuildFromEnv_Extra(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("extra_completions", "completions/pkg.bash")
+	os.Setenv("extra_shell_name", "zsh")
+	os.Setenv("extra_empty", "")
+	os.Setenv("extra_", "nameless")
+	os.Setenv("EXTRA_UPPER", "ignored")
+
+	result := NewPkgBuildFromEnv()
+
+	assert.Equal(t, map[string]string{"Completions": "completions/pkg.bash", "ShellName": "zsh"}, result.Extra)
+}
+
+func TestNewPkgBuildFromEnv_Install(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("pkgname", "test-bin")
+	os.Setenv("post_install", "echo installed\n")
+	os.Setenv("pre_remove", "echo removing")
+
+	result := NewPkgBuildFromEnv()
+
+	assert.Equal(t, "test-bin.install", result.Install)
+	assert.Equal(t, "echo installed", result.PostInstall)
+	assert.Empty(t, result.PostUpgrade)
+	assert.Equal(t, "echo removing", result.PreRemove)
+
+	os.Clearenv()
+	os.Setenv("pkgname", "test-bin")
+	os.Setenv("install_template", "install.tmpl")
+	result = NewPkgBuildFromEnv()
+	assert.Equal(t, "test-bin.install", result.Install)
+	assert.Equal(t, "install.tmpl", result.installTemplatePath)
+
+	os.Clearenv()
+	assert.Empty(t, NewPkgBuildFromEnv().Install)
+}
+
+func TestNewPkgBuildFromEnv_LocalSources(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("local_sources", "packaging/test.service,LICENSE")
+
+	result := NewPkgBuildFromEnv()
+
+	assert.Equal(t, []string{"packaging/test.service", "LICENSE"}, result.localSources)
+}
+
+func TestNewPkgBuildFromEnv_Arrays(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("replaces", "old-test")
+	os.Setenv("groups", "test-tools")
+	os.Setenv("optdepends", "git: clone repositories")
+	os.Setenv("checkdepends", "bats")
+	os.Setenv("backup", "etc/test/config.toml")
+	os.Setenv("options", "!strip,!debug")
+	os.Setenv("noextract", "test.tar.gz")
+	os.Setenv("changelog", "packaging/CHANGELOG.md")
+
+	result := NewPkgBuildFromEnv()
+
+	assert.Equal(t, []string{"old-test"}, result.Replaces)
+	assert.Equal(t, []string{"test-tools"}, result.Groups)
+	assert.Equal(t, []string{"git: clone repositories"}, result.Optdepends)
+	assert.Equal(t, []string{"bats"}, result.Checkdepends)
+	assert.Equal(t, []string{"etc/test/config.toml"}, result.Backup)
+	assert.Equal(t, []string{"!strip", "!debug"}, result.Options)
+	assert.Equal(t, []string{"test.tar.gz"}, result.Noextract)
+	assert.Equal(t, "CHANGELOG.md", result.Changelog)
+	assert.Equal(t, "packaging/CHANGELOG.md", result.changelogPath)
+}
+
+func TestNewPkgBuildFromEnv_Aur(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("aur_url", "https://aur.example.com")
+	os.Setenv("aur_rpc_path", "/api/rpc")
+	os.Setenv("aur_rpc_version", "6")
+	os.Setenv("aur_plain_url", "/cgit/{pkgname}.git/plain/{file}")
+	os.Setenv("aur_mirror", "github")
+	os.Setenv("aur_usernames", "fuad-daoud,release-bot")
+	os.Setenv("adopt_orphans", "true")
+
+	result := NewPkgBuildFromEnv()
+
+	assert.Equal(t, "https://aur.example.com", result.aurURL)
+	assert.Equal(t, "/api/rpc", result.aurRPCPath)
+	assert.Equal(t, "6", result.aurRPCVersion)
+	assert.Equal(t, "/cgit/{pkgname}.git/plain/{file}", result.aurPlainURL)
+	assert.Equal(t, githubAurMirror, result.aurMirrorURL)
+	assert.Equal(t, []string{"fuad-daoud", "release-bot"}, result.aurUsernames)
+	assert.True(t, result.adoptOrphans)
+}
+
+func TestRemoteWarnings(t *testing.T) {
+	pkgbuild := PkgBuild{
+		Pkgname:     "test-pkg",
+		Maintainers: []string{"Fuad Daoud <aur@fuad-daoud.com>"},
+		Depends:     []string{"glibc", "git"},
+		Licence:     []string{"MIT"},
+	}
+
+	tests := []struct {
+		name     string
+		info     AurPackage
+		expected []string
+	}{
+		{
+			name: "no differences",
+			info: AurPackage{Name: "test-pkg", PackageBase: "test-pkg", Maintainer: "fuad-daoud", Depends: []string{"git", "glibc"}, License: []string{"MIT"}},
+		},
+		{
+			name: "read from mirror",
+			info: AurPackage{},
+		},
+		{
+			name:     "maintained by someone else",
+			info:     AurPackage{Name: "test-pkg", Maintainer: "someone", Depends: []string{"git", "glibc"}, License: []string{"MIT"}},
+			expected: []string{"test-pkg is maintained by someone on the AUR"},
+		},
+		{
+			name:     "orphaned",
+			info:     AurPackage{Name: "test-pkg", Depends: []string{"git", "glibc"}, License: []string{"MIT"}},
+			expected: []string{"test-pkg is orphaned on the AUR"},
+		},
+		{
+			name: "out of date and differences",
+			info: AurPackage{Name: "test-pkg", PackageBase: "test", Maintainer: "Fuad", OutOfDate: 1735732800, Depends: []string{"glibc"}, License: []string{"GPL-3.0-only"}},
+			expected: []string{
+				"test-pkg is flagged out of date on the AUR since 2025-01-01",
+				"test-pkg belongs to the package base test on the AUR",
+				"depends change from [glibc] on the AUR to [glibc git]",
+				"licenses change from [GPL-3.0-only] on the AUR to [MIT]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, remoteWarnings(pkgbuild, tt.info))
+		})
+	}
+}
+
+func TestCheckOwnership(t *testing.T) {
+	tests := []struct {
+		name         string
+		usernames    []string
+		adoptOrphans bool
+		info         AurPackage
+		errContains  string
+	}{
+		{
+			name: "no usernames configured",
+			info: AurPackage{Name: "test-pkg", Maintainer: "someone"},
+		},
+		{
+			name:      "maintainer",
+			usernames: []string{"fuad-daoud"},
+			info:      AurPackage{Name: "test-pkg", Maintainer: "Fuad-Daoud"},
+		},
+		{
+			name:      "co-maintainer",
+			usernames: []string{"release-bot"},
+			info:      AurPackage{Name: "test-pkg", Maintainer: "someone", CoMaintainers: []string{"release-bot"}},
+		},
+		{
+			name:        "info read from mirror",
+			usernames:   []string{"fuad-daoud"},
+			info:        AurPackage{},
+			errContains: "remote unavailable: cannot check the maintainer of test-pkg without the AUR RPC",
+		},
+		{
+			name:        "someone else",
+			usernames:   []string{"fuad-daoud", "release-bot"},
+			info:        AurPackage{Name: "test-pkg", Maintainer: "someone", CoMaintainers: []string{"helper"}},
+			errContains: "test-pkg is maintained by someone on the AUR, not by any of [fuad-daoud release-bot]",
+		},
+		{
+			name:        "orphan",
+			usernames:   []string{"fuad-daoud"},
+			info:        AurPackage{Name: "test-pkg"},
+			errContains: "test-pkg is orphaned on the AUR",
+		},
+		{
+			name:         "orphan in adopt mode",
+			usernames:    []string{"fuad-daoud"},
+			adoptOrphans: true,
+			info:         AurPackage{Name: "test-pkg"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkgbuild := PkgBuild{Pkgname: "test-pkg", aurUsernames: tt.usernames, adoptOrphans: tt.adoptOrphans}
+			err := checkOwnership(pkgbuild, tt.info)
+			if tt.errContains != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errContains)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestDefaultCompareWithRemote_Ownership(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"resultcount":1,"results":[{"Name":"test-pkg","Version":"0.9.0-1","Maintainer":"someone"}]}`))
+	}))
+	defer server.Close()
+
+	pkgbuild := PkgBuild{Pkgname: "test-pkg", Version: "1.0.0", aurUsernames: []string{"fuad-daoud"}}
+	_, err := defaultCompareWithRemote(context.Background(), DummyClient(server), pkgbuild, "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "maintained by someone")
+
+	pkgbuild.aurUsernames = []string{"someone"}
+	remote, err := defaultCompareWithRemote(context.Background(), DummyClient(server), pkgbuild, "")
+	assert.NoError(t, err)
+	assert.Equal(t, remoteState{decision: decisionNewVersion, version: "0.9.0", pkgrel: "1"}, remote)
+}
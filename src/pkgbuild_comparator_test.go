@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -23,10 +24,10 @@ func TestDefaultCompareWithRemote_NewPackage(t *testing.T) {
 		Checksum_x86_64: []string{"abc123"},
 	}
 
-	pkgrel, err := defaultCompareWithRemote(client, pkgbuild, "test PKGBUILD content")
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, "test PKGBUILD content")
 
 	assert.NoError(t, err)
-	assert.Equal(t, -1, pkgrel, "New package should return -1")
+	assert.Equal(t, remoteState{decision: decisionNewPackage}, remote, "New package should not bump pkgrel")
 }
 
 func TestDefaultCompareWithRemote_NewVersion(t *testing.T) {
@@ -42,10 +43,10 @@ func TestDefaultCompareWithRemote_NewVersion(t *testing.T) {
 		Checksum_x86_64: []string{"abc123"},
 	}
 
-	pkgrel, err := defaultCompareWithRemote(client, pkgbuild, "test PKGBUILD content")
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, "test PKGBUILD content")
 
 	assert.NoError(t, err)
-	assert.Equal(t, -1, pkgrel, "New version should return -1")
+	assert.Equal(t, decisionNewVersion, remote.decision, "New version should not bump pkgrel")
 }
 
 func TestDefaultCompareWithRemote_SameVersionDifferentContent(t *testing.T) {
@@ -75,10 +76,11 @@ pkgrel=1
 description="Different description"
 sha256sums_x86_64=('abc123')`
 
-	pkgrel, err := defaultCompareWithRemote(client, pkgbuild, localPKGBUILD)
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, localPKGBUILD)
 
 	assert.NoError(t, err)
-	assert.Equal(t, 2, pkgrel, "Should increment pkgrel from remote")
+	assert.Equal(t, decisionPkgrelBump, remote.decision)
+	assert.Equal(t, "2", remote.pkgrel, "Should increment pkgrel from remote")
 }
 
 func TestDefaultCompareWithRemote_SameVersionSameContent(t *testing.T) {
@@ -103,11 +105,243 @@ sha256sums_x86_64=('abc123')`
 		Checksum_x86_64: []string{"abc123"},
 	}
 
-	pkgrel, err := defaultCompareWithRemote(client, pkgbuild, localPKGBUILD)
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, localPKGBUILD)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "already published")
-	assert.Equal(t, -1, pkgrel)
+	assert.Equal(t, remoteState{}, remote)
+}
+
+func TestDefaultCompareWithRemote_SameVersionSameContentIdempotent(t *testing.T) {
+	localPKGBUILD := `pkgname=test
+pkgver=1.0.0
+pkgrel=1
+sha256sums_x86_64=('abc123')`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "rpc") {
+			w.Write([]byte(`{"resultcount":1,"results":[{"Name":"test","Version":"1.0.0-2"}]}`))
+		} else if strings.Contains(r.URL.Path, "PKGBUILD") {
+			w.Write([]byte(localPKGBUILD))
+		}
+	}))
+	defer server.Close()
+
+	client := DummyClient(server)
+	pkgbuild := PkgBuild{
+		Pkgname:         "test",
+		Version:         "1.0.0",
+		Checksum_x86_64: []string{"abc123"},
+		idempotent:      true,
+	}
+
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, localPKGBUILD)
+
+	assert.NoError(t, err)
+	assert.Equal(t, remoteState{decision: decisionUpToDate, version: "1.0.0", pkgrel: "2", pkgbuild: localPKGBUILD, warnings: []string{"test is orphaned on the AUR"}}, remote)
+}
+
+func TestDefaultCompareWithRemote_SameVersionNewChecksumIdempotent(t *testing.T) {
+	remotePKGBUILD := `pkgname=test
+pkgver=1.0.0
+pkgrel=2
+sha256sums_x86_64=('abc123')`
+	localPKGBUILD := `pkgname=test
+pkgver=1.0.0
+pkgrel=1
+sha256sums_x86_64=('def456')`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "rpc") {
+			w.Write([]byte(`{"resultcount":1,"results":[{"Name":"test","Version":"1.0.0-2"}]}`))
+		} else if strings.Contains(r.URL.Path, "PKGBUILD") {
+			w.Write([]byte(remotePKGBUILD))
+		}
+	}))
+	defer server.Close()
+
+	client := DummyClient(server)
+	pkgbuild := PkgBuild{
+		Pkgname:         "test",
+		Version:         "1.0.0",
+		Checksum_x86_64: []string{"def456"},
+		idempotent:      true,
+	}
+
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, localPKGBUILD)
+
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.Equal(t, remoteState{}, remote)
+}
+
+func TestDefaultCompareWithRemote_SameVersionSameContentInstall(t *testing.T) {
+	localPKGBUILD := `pkgname=test
+pkgver=1.0.0
+pkgrel=1
+install=test.install
+sha256sums_x86_64=('abc123')`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "rpc") {
+			w.Write([]byte(`{"resultcount":1,"results":[{"Name":"test","Version":"1.0.0-1"}]}`))
+		} else if strings.Contains(r.URL.Path, "PKGBUILD") {
+			w.Write([]byte(localPKGBUILD))
+		} else if strings.Contains(r.URL.Path, "test.install") {
+			w.Write([]byte("post_install() {\n    echo installed\n}\n"))
+		}
+	}))
+	defer server.Close()
+
+	client := DummyClient(server)
+	pkgbuild := PkgBuild{
+		Pkgname:         "test",
+		Version:         "1.0.0",
+		Checksum_x86_64: []string{"abc123"},
+		Install:         "test.install",
+		installScript:   "post_install() {\n    echo installed\n}\n",
+	}
+
+	_, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, localPKGBUILD)
+	assert.ErrorIs(t, err, ErrAlreadyPublished)
+
+	pkgbuild.installScript = "post_install() {\n    echo 'installed, enable the unit'\n}\n"
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, localPKGBUILD)
+	assert.NoError(t, err)
+	assert.Equal(t, decisionPkgrelBump, remote.decision)
+}
+
+func TestDefaultCompareWithRemote_SameVersionSameContentChangelog(t *testing.T) {
+	localPKGBUILD := `pkgname=test
+pkgver=1.0.0
+pkgrel=1
+changelog=CHANGELOG.md
+sha256sums_x86_64=('abc123')`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "rpc") {
+			w.Write([]byte(`{"resultcount":1,"results":[{"Name":"test","Version":"1.0.0-1"}]}`))
+		} else if strings.Contains(r.URL.Path, "PKGBUILD") {
+			w.Write([]byte(localPKGBUILD))
+		} else if strings.Contains(r.URL.Path, "CHANGELOG.md") {
+			w.Write([]byte("# 1.0.0\n"))
+		}
+	}))
+	defer server.Close()
+
+	client := DummyClient(server)
+	pkgbuild := PkgBuild{
+		Pkgname:         "test",
+		Version:         "1.0.0",
+		Checksum_x86_64: []string{"abc123"},
+		Changelog:       "CHANGELOG.md",
+		changelog:       "# 1.0.0\n",
+	}
+
+	_, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, localPKGBUILD)
+	assert.ErrorIs(t, err, ErrAlreadyPublished)
+
+	pkgbuild.changelog = "# 1.0.0\n\n- Packaging fixes\n"
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, localPKGBUILD)
+	assert.NoError(t, err)
+	assert.Equal(t, decisionPkgrelBump, remote.decision)
+}
+
+func TestDefaultCompareWithRemote_SameVersionLocalSources(t *testing.T) {
+	remotePKGBUILD := `pkgname=test
+pkgver=1.0.0
+pkgrel=1
+source=(
+"LICENSE::https://example.com/LICENSE"
+"test.service"
+)
+sha256sums=(
+'license'
+'service'
+)
+sha256sums_x86_64=('abc123')`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "rpc") {
+			w.Write([]byte(`{"resultcount":1,"results":[{"Name":"test","Version":"1.0.0-1"}]}`))
+		} else if strings.Contains(r.URL.Path, "PKGBUILD") {
+			w.Write([]byte(remotePKGBUILD))
+		}
+	}))
+	defer server.Close()
+
+	client := DummyClient(server)
+	pkgbuild := PkgBuild{
+		Pkgname:         "test",
+		Version:         "1.0.0",
+		Source:          []string{"LICENSE::https://example.com/LICENSE", "test.service"},
+		Checksum:        []string{"license", "service"},
+		Checksum_x86_64: []string{"abc123"},
+		localSources:    []string{"packaging/test.service"},
+	}
+
+	_, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, remotePKGBUILD)
+	assert.ErrorIs(t, err, ErrAlreadyPublished)
+
+	pkgbuild.Checksum = []string{"license", "changed-service"}
+	local := strings.Replace(remotePKGBUILD, "'service'", "'changed-service'", 1)
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, local)
+	assert.NoError(t, err)
+	assert.Equal(t, decisionPkgrelBump, remote.decision)
+
+	pkgbuild.Checksum = []string{"changed-license", "changed-service"}
+	_, err = defaultCompareWithRemote(context.Background(), client, pkgbuild, local)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+func TestDefaultCompareWithRemote_DroppedLocalSource(t *testing.T) {
+	remotePKGBUILD := `pkgname=test
+pkgver=1.0.0
+pkgrel=1
+source=(
+"LICENSE::https://example.com/LICENSE"
+"test.service"
+)
+sha256sums=(
+'license'
+'service'
+)
+sha256sums_x86_64=('abc123')`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "rpc") {
+			w.Write([]byte(`{"resultcount":1,"results":[{"Name":"test","Version":"1.0.0-1"}]}`))
+		} else if strings.Contains(r.URL.Path, "PKGBUILD") {
+			w.Write([]byte(remotePKGBUILD))
+		}
+	}))
+	defer server.Close()
+
+	client := DummyClient(server)
+	pkgbuild := PkgBuild{
+		Pkgname:         "test",
+		Version:         "1.0.0",
+		Source:          []string{"LICENSE::https://example.com/LICENSE"},
+		Checksum:        []string{"license"},
+		Checksum_x86_64: []string{"abc123"},
+	}
+	local := `pkgname=test
+pkgver=1.0.0
+pkgrel=1
+source=(
+"LICENSE::https://example.com/LICENSE"
+)
+sha256sums=(
+'license'
+)
+sha256sums_x86_64=('abc123')`
+
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, local)
+	assert.NoError(t, err)
+	assert.Equal(t, decisionPkgrelBump, remote.decision)
+
+	pkgbuild.Checksum = []string{"changed-license"}
+	_, err = defaultCompareWithRemote(context.Background(), client, pkgbuild, local)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
 }
 
 func TestDefaultCompareWithRemote_SameVersionX86_64NewChecksums(t *testing.T) {
@@ -137,11 +371,11 @@ description="new description"
 pkgver=1.0.0
 sha256sums_x86_64=('newchecksum')`
 
-	pkgrel, err := defaultCompareWithRemote(client, pkgbuild, localPKGBUILD)
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, localPKGBUILD)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "different x86_64 checksums")
-	assert.Equal(t, -1, pkgrel)
+	assert.Equal(t, remoteState{}, remote)
 }
 
 func TestDefaultCompareWithRemote_SameVersionAARCH64NewChecksums(t *testing.T) {
@@ -171,11 +405,11 @@ description="new description"
 pkgver=1.0.0
 sha256sums_aarch64=('newchecksum')`
 
-	pkgrel, err := defaultCompareWithRemote(client, pkgbuild, localPKGBUILD)
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, localPKGBUILD)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "different aarch64 checksums")
-	assert.Equal(t, -1, pkgrel)
+	assert.Equal(t, remoteState{}, remote)
 }
 
 func TestDefaultCompareWithRemote_MultipleArchitectures(t *testing.T) {
@@ -207,10 +441,11 @@ description="Updated description"
 sha256sums_x86_64=('checksum1')
 sha256sums_aarch64=('checksum2')`
 
-	pkgrel, err := defaultCompareWithRemote(client, pkgbuild, localPKGBUILD)
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, localPKGBUILD)
 
 	assert.NoError(t, err)
-	assert.Equal(t, 3, pkgrel)
+	assert.Equal(t, decisionPkgrelBump, remote.decision)
+	assert.Equal(t, "3", remote.pkgrel)
 }
 
 func TestDefaultCompareWithRemote_FetchVersionError(t *testing.T) {
@@ -225,10 +460,10 @@ func TestDefaultCompareWithRemote_FetchVersionError(t *testing.T) {
 		Version: "1.0.0",
 	}
 
-	pkgrel, err := defaultCompareWithRemote(client, pkgbuild, "test")
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, "test")
 
 	assert.Error(t, err)
-	assert.Equal(t, -1, pkgrel)
+	assert.Equal(t, remoteState{}, remote)
 }
 
 func TestDefaultCompareWithRemote_FetchPKGBUILDError(t *testing.T) {
@@ -248,10 +483,10 @@ func TestDefaultCompareWithRemote_FetchPKGBUILDError(t *testing.T) {
 		Checksum_x86_64: []string{"abc"},
 	}
 
-	pkgrel, err := defaultCompareWithRemote(client, pkgbuild, "test")
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, "test")
 
 	assert.Error(t, err)
-	assert.Equal(t, -1, pkgrel)
+	assert.Equal(t, remoteState{}, remote)
 }
 
 func TestDefaultCompareWithRemote_Checksumx86_64CountMismatch(t *testing.T) {
@@ -280,11 +515,11 @@ pkgver=1.0.0
 description="new description"
 sha256sums_x86_64=('checksum1')`
 
-	pkgrel, err := defaultCompareWithRemote(client, pkgbuild, localPKGBUILD)
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, localPKGBUILD)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "different number of x86_64 checksums")
-	assert.Equal(t, -1, pkgrel)
+	assert.Equal(t, remoteState{}, remote)
 }
 
 func TestDefaultCompareWithRemote_ChecksumAARCH64CountMismatch(t *testing.T) {
@@ -313,11 +548,11 @@ pkgver=1.0.0
 description="new description"
 sha256sums_aarch64=('checksum1')`
 
-	pkgrel, err := defaultCompareWithRemote(client, pkgbuild, localPKGBUILD)
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, localPKGBUILD)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "different number of aarch64 checksums")
-	assert.Equal(t, -1, pkgrel)
+	assert.Equal(t, remoteState{}, remote)
 }
 
 func TestDefaultCompareWithRemote_RealPKGBUILD(t *testing.T) {
@@ -342,7 +577,7 @@ func TestDefaultCompareWithRemote_RealPKGBUILD(t *testing.T) {
 
 	localPKGBUILD := string(aurPKGBUILD)
 
-	_, err = defaultCompareWithRemote(client, pkgbuild, localPKGBUILD)
+	_, err = defaultCompareWithRemote(context.Background(), client, pkgbuild, localPKGBUILD)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "already published")
@@ -377,8 +612,9 @@ description="Updated description"
 sha256sums_x86_64=('checksum1')
 sha256sums_aarch64=('checksum2')`
 
-	pkgrel, err := defaultCompareWithRemote(client, pkgbuild, localPKGBUILD)
+	remote, err := defaultCompareWithRemote(context.Background(), client, pkgbuild, localPKGBUILD)
 
 	assert.NoError(t, err)
-	assert.Equal(t, 3, pkgrel)
+	assert.Equal(t, decisionPkgrelBump, remote.decision)
+	assert.Equal(t, "3", remote.pkgrel)
 }
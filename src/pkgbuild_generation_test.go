@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -15,20 +16,18 @@ func TestGenerateNewVersion(t *testing.T) {
 		Maintainers:          []string{"Fuad Daoud <aur@fuad-daoud.com>"},
 		Pkgname:              "pkgmate-bin",
 		Version:              "v0.0.0-test-release-aur",
-		Pkgrel:               1,
+		Pkgrel:               "1",
 		Description:          "TUI application to manage your dependencies",
 		Url:                  "https://github.com/fuad-daoud/pkgmate",
 		Arch:                 []string{"x86_64"},
 		Licence:              []string{"MIT"},
 		Source_x86_64:        []string{"pkgmate-bin-v0.0.0-test-release-aur-x86_64::https://github.com/fuad-daoud/pkgmate/releases/download/v0.0.0-test-release-aur/pkgmate-linux-amd64"},
-		pkgbuildTemplatePath: "pkgbuild.tmpl",
-		srcInfoTemplatePath:  "srcinfo.tmpl",
 		outputPath:           "./output/",
 		comparator:           defaultCompareWithRemote,
 		checksumCalculator:   parser.DefaultCalculateSources,
 	}
 
-	PKGBUILD, err := pkgbuild.generate()
+	PKGBUILD, err := pkgbuild.generate(context.Background())
 	if err != nil {
 		t.Errorf("got an err %v", err)
 	}
@@ -48,7 +47,7 @@ func TestGenerateNewPkgrel(t *testing.T) {
 		Maintainers:     []string{"Fuad Daoud <aur@fuad-daoud.com>"},
 		Pkgname:         "pkgmate-bin",
 		Version:         "0.1.1",
-		Pkgrel:          1,
+		Pkgrel:          "1",
 		Description:     "TUI application to manage your dependencies",
 		Url:             "https://github.com/fuad-daoud/pkgmate",
 		Arch:            []string{"x86_64"},
@@ -57,13 +56,11 @@ func TestGenerateNewPkgrel(t *testing.T) {
 		Checksum_x86_64: []string{"SKIP"},
 
 		outputPath:           "./output/",
-		pkgbuildTemplatePath: "pkgbuild.tmpl",
-		srcInfoTemplatePath:  "srcinfo.tmpl",
 		comparator:           defaultCompareWithRemote,
 		checksumCalculator:   parser.DefaultCalculateSources,
 	}
 
-	PKGBUILD, err := pkgbuild.generate()
+	PKGBUILD, err := pkgbuild.generate(context.Background())
 	if err != nil {
 		t.Errorf("got an err %v", err)
 	}
@@ -90,16 +87,15 @@ func TestGenerate_Errors(t *testing.T) {
 			Licence:              []string{"MIT"},
 			Source_x86_64:        []string{"https://github.com/fuad-daoud/pkgmate/releases/download/0.1.1/pkgmate-linux-amd64"},
 			pkgbuildTemplatePath: "./nonexistent.tmpl",
-			srcInfoTemplatePath:  "srcinfo.tmpl",
 			checksumCalculator:   parser.DefaultCalculateSources,
 		}
 
-		_, err := pkg.generate()
+		_, err := pkg.generate(context.Background())
 		assert.Error(t, err)
 	})
 	t.Run("PKGBUILDs match - new pkgrel, second templating fails", func(t *testing.T) {
 
-		err := copyFile("pkgbuild.tmpl", "/tmp/pkgbuild.tmpl")
+		err := copyFile("templates/srcinfo.tmpl", "/tmp/pkgbuild.tmpl")
 		if err != nil {
 			t.Errorf("error in setup, %v", err)
 		}
@@ -115,13 +111,12 @@ func TestGenerate_Errors(t *testing.T) {
 			Licence:              []string{"MIT"},
 			Source_x86_64:        []string{"https://github.com/fuad-daoud/pkgmate/releases/download/0.1.1/pkgmate-linux-amd64"},
 			pkgbuildTemplatePath: "/tmp/pkgbuild.tmpl",
-			srcInfoTemplatePath:  "srcinfo.tmpl",
-			comparator: func(Client, PkgBuild, string) (int, error) {
+			comparator: func(context.Context, Client, PkgBuild, string) (remoteState, error) {
 				err := copyFile("invalid_template.tmpl", "/tmp/pkgbuild.tmpl")
 				if err != nil {
 					t.Errorf("error in setup, %v", err)
 				}
-				return 1, nil
+				return remoteState{decision: decisionPkgrelBump, pkgrel: "1"}, nil
 
 			},
 
@@ -129,7 +124,7 @@ func TestGenerate_Errors(t *testing.T) {
 			outputPath:         "/root/",
 		}
 
-		_, err = pkg.generate()
+		_, err = pkg.generate(context.Background())
 		if err == nil {
 			t.Errorf("should have retruned an error")
 		}
@@ -148,14 +143,12 @@ func TestGenerate_Errors(t *testing.T) {
 			Licence:              []string{"MIT"},
 			Source_x86_64:        []string{"https://github.com/fuad-daoud/pkgmate/releases/download/0.1.1/pkgmate-linux-amd64"},
 			Checksum_x86_64:      []string{"SKIP"},
-			pkgbuildTemplatePath: "pkgbuild.tmpl",
-			srcInfoTemplatePath:  "srcinfo.tmpl",
 			outputPath:           "/root/",
-			comparator:           func(Client, PkgBuild, string) (int, error) { return -1, nil },
+			comparator:           func(context.Context, Client, PkgBuild, string) (remoteState, error) { return remoteState{decision: decisionNewVersion}, nil },
 			checksumCalculator:   parser.DefaultCalculateSources,
 		}
 
-		_, err := pkg.generate()
+		_, err := pkg.generate(context.Background())
 		if err == nil {
 			t.Errorf("should have retruned an error")
 		}
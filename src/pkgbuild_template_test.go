@@ -2,6 +2,8 @@ package main
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,31 +15,32 @@ func TestTemplate(t *testing.T) {
 		pkg              PkgBuild
 		expectedPKGBUILD string
 		expectedSRCINFO  string
+		// builtin renders the same PKGBUILD as pkgbuildTemplatePath
+		builtin string
 	}{
 		{
 			name: "Test all fields",
 			pkg: PkgBuild{
-				CliName:              "pkg",
-				Maintainers:          []string{"Fuad Daoud <aur@fuad-daoud.com>", "Fuad2 Daoud2 <aur2@fuad-daoud.com>"},
-				Contributors:         []string{"Someone else <someone@fuad-daoud.com>", "Someone2 else2  <someone2@fuad-daoud.com>"},
-				Pkgname:              "pkg-bin",
-				Version:              "0.1.4",
-				Pkgrel:               1,
-				Description:          "Some single line description",
-				Url:                  "https://github.com/fuad-daoud/pkg",
-				Arch:                 []string{"x86_64", "aarch64"},
-				Licence:              []string{"MIT", "OBSD"},
-				Provides:             []string{"package-a", "package-b"},
-				Conflicts:            []string{"package-c", "package-d"},
-				Source_x86_64:        []string{"pkg-bin-0.1.4-x86_64::https://github.com/fuad-daoud/pkg/releases/download/v0.1.4/prayers-linux-x86_64", "LICENSE::https://raw.githubusercontent.com/fuad-daoud/pkg/v0.1.4/LICENSE", "README::https://raw.githubusercontent.com/fuad-daoud/pkg/v0.1.4/README.md"},
-				Source_aarch64:       []string{"pkg-bin-0.1.4-aarch_64::https://github.com/fuad-daoud/pkg/releases/download/v0.1.4/prayers-linux-aarch_64", "LICENSE::https://raw.githubusercontent.com/fuad-daoud/pkg/v0.1.4/LICENSE", "README::https://raw.githubusercontent.com/fuad-daoud/pkg/v0.1.4/README.md"},
-				Checksum_x86_64:      []string{"ce9b515fd45526ac641d7bcf8520f58a72dc82d8109cfe3b317484cd837c5cec", "ce9b515fd45526ac641d7bcf8520f58a72dc82d8109cfe3b317484cd837c5cec", "ce9b515fd45526ac641d7bcf8520f58a72dc82d8109cfe3b317484cd837c5cec"},
-				Checksum_aarch64:     []string{"SKIP"},
-				pkgbuildTemplatePath: "pkgbuild.tmpl",
-				srcInfoTemplatePath:  "srcinfo.tmpl",
+				CliName:          "pkg",
+				Maintainers:      []string{"Fuad Daoud <aur@fuad-daoud.com>", "Fuad2 Daoud2 <aur2@fuad-daoud.com>"},
+				Contributors:     []string{"Someone else <someone@fuad-daoud.com>", "Someone2 else2  <someone2@fuad-daoud.com>"},
+				Pkgname:          "pkg-bin",
+				Version:          "0.1.4",
+				Pkgrel:           "1",
+				Description:      "Some single line description",
+				Url:              "https://github.com/fuad-daoud/pkg",
+				Arch:             []string{"x86_64", "aarch64"},
+				Licence:          []string{"MIT", "OBSD"},
+				Provides:         []string{"package-a", "package-b"},
+				Conflicts:        []string{"package-c", "package-d"},
+				Source_x86_64:    []string{"pkg-bin-0.1.4-x86_64::https://github.com/fuad-daoud/pkg/releases/download/v0.1.4/prayers-linux-x86_64", "LICENSE::https://raw.githubusercontent.com/fuad-daoud/pkg/v0.1.4/LICENSE", "README::https://raw.githubusercontent.com/fuad-daoud/pkg/v0.1.4/README.md"},
+				Source_aarch64:   []string{"pkg-bin-0.1.4-aarch_64::https://github.com/fuad-daoud/pkg/releases/download/v0.1.4/prayers-linux-aarch_64", "LICENSE::https://raw.githubusercontent.com/fuad-daoud/pkg/v0.1.4/LICENSE", "README::https://raw.githubusercontent.com/fuad-daoud/pkg/v0.1.4/README.md"},
+				Checksum_x86_64:  []string{"ce9b515fd45526ac641d7bcf8520f58a72dc82d8109cfe3b317484cd837c5cec", "ce9b515fd45526ac641d7bcf8520f58a72dc82d8109cfe3b317484cd837c5cec", "ce9b515fd45526ac641d7bcf8520f58a72dc82d8109cfe3b317484cd837c5cec"},
+				Checksum_aarch64: []string{"SKIP"},
 			},
 			expectedPKGBUILD: "testdata/PKGBUILD",
 			expectedSRCINFO:  "testdata/.SRCINFO",
+			builtin:          "bin-single",
 		},
 		{
 			pkg: PkgBuild{
@@ -46,7 +49,7 @@ func TestTemplate(t *testing.T) {
 				Contributors:    []string{"Someone else <someone@fuad-daoud.com>", "Someone2 else2  <someone2@fuad-daoud.com>"},
 				Pkgname:         "pkg-bin",
 				Version:         "0.1.4",
-				Pkgrel:          1,
+				Pkgrel:          "1",
 				Description:     "Some single line description",
 				Url:             "https://github.com/fuad-daoud/pkg",
 				Arch:            []string{"x86_64", "aarch64"},
@@ -55,12 +58,77 @@ func TestTemplate(t *testing.T) {
 				Conflicts:       []string{"package-c", "package-d"},
 				Source_x86_64:   []string{"pkg-bin-0.1.4-x86_64::https://github.com/fuad-daoud/pkg/releases/download/v0.1.4/prayers-linux-x86_64", "LICENSE::https://raw.githubusercontent.com/fuad-daoud/pkg/v0.1.4/LICENSE", "README::https://raw.githubusercontent.com/fuad-daoud/pkg/v0.1.4/README.md"},
 				Checksum_x86_64: []string{"CHECKSUM1", "CHECKSUM2", "CHECKSUM3"},
-
-				pkgbuildTemplatePath: "pkgbuild.tmpl",
-				srcInfoTemplatePath:  "srcinfo.tmpl",
 			},
 			expectedPKGBUILD: "testdata/PKGBUILD_x86",
 			expectedSRCINFO:  "testdata/.SRCINFO_x86",
+			builtin:          "bin-single",
+		},
+		{
+			name: "Signed sources",
+			pkg: PkgBuild{
+				CliName:         "pkg",
+				Maintainers:     []string{"Fuad Daoud <aur@fuad-daoud.com>"},
+				Pkgname:         "pkg-bin",
+				Version:         "0.1.4",
+				Pkgrel:          "1",
+				Description:     "Some single line description",
+				Url:             "https://github.com/fuad-daoud/pkg",
+				Arch:            []string{"x86_64"},
+				Licence:         []string{"MIT"},
+				Source_x86_64:   []string{"pkg-bin-0.1.4-x86_64::https://github.com/fuad-daoud/pkg/releases/download/v0.1.4/pkg-linux-amd64", "pkg-bin-0.1.4-x86_64.sig::https://github.com/fuad-daoud/pkg/releases/download/v0.1.4/pkg-linux-amd64.sig"},
+				Checksum_x86_64: []string{"CHECKSUM1", "CHECKSUM2"},
+				Validpgpkeys:    []string{"ABCD1234ABCD1234ABCD1234ABCD1234ABCD1234"},
+			},
+			expectedPKGBUILD: "testdata/PKGBUILD_signed",
+			expectedSRCINFO:  "testdata/.SRCINFO_signed",
+			builtin:          "bin-single",
+		},
+		{
+			name: "Source mode with go preset",
+			pkg: PkgBuild{
+				CliName:     "pkg",
+				Maintainers: []string{"Fuad Daoud <aur@fuad-daoud.com>"},
+				Pkgname:     "pkg",
+				Version:     "0.1.4",
+				Pkgrel:      "1",
+				Description: "Some single line description",
+				Url:         "https://github.com/fuad-daoud/pkg",
+				Arch:        []string{"x86_64", "aarch64"},
+				Licence:     []string{"MIT"},
+				Depends:     []string{"glibc"},
+				Makedepends: []string{"go"},
+				Source:      []string{"pkg-0.1.4.tar.gz::https://github.com/fuad-daoud/pkg/archive/refs/tags/v0.1.4.tar.gz"},
+				Checksum:    []string{"CHECKSUM1"},
+				Mode:        "source",
+				BuildPreset: "go",
+				SourceDir:   "$pkgname-$pkgver",
+			},
+			expectedPKGBUILD: "testdata/PKGBUILD_source_go",
+			expectedSRCINFO:  "testdata/.SRCINFO_source_go",
+			builtin:          "source-go",
+		},
+		{
+			name: "Source mode with rust preset",
+			pkg: PkgBuild{
+				CliName:     "pkg",
+				Maintainers: []string{"Fuad Daoud <aur@fuad-daoud.com>"},
+				Pkgname:     "pkg",
+				Version:     "0.1.4",
+				Pkgrel:      "1",
+				Description: "Some single line description",
+				Url:         "https://github.com/fuad-daoud/pkg",
+				Arch:        []string{"x86_64"},
+				Licence:     []string{"MIT"},
+				Makedepends: []string{"cargo"},
+				Source:      []string{"pkg-0.1.4.tar.gz::https://github.com/fuad-daoud/pkg/archive/refs/tags/v0.1.4.tar.gz"},
+				Checksum:    []string{"CHECKSUM1"},
+				Mode:        "source",
+				BuildPreset: "rust",
+				SourceDir:   "$pkgname-$pkgver",
+			},
+			expectedPKGBUILD: "testdata/PKGBUILD_source_rust",
+			expectedSRCINFO:  "testdata/.SRCINFO_source_rust",
+			builtin:          "source-rust",
 		},
 	}
 
@@ -73,10 +141,293 @@ func TestTemplate(t *testing.T) {
 			expectedSRCINFO, _ := os.ReadFile(tt.expectedSRCINFO)
 			assert.EqualValuesf(t, string(expectedPKGBUILD), pkgbuild, "Failed Templating")
 			assert.EqualValuesf(t, string(expectedSRCINFO), srcinfo, "Failed Templating")
+
+			builtin := tt.pkg
+			builtin.templateName = tt.builtin
+			pkgbuild, srcinfo, err = builtin.template()
+			assert.NoError(t, err)
+			assert.Equal(t, string(expectedPKGBUILD), pkgbuild, "built-in template %s", tt.builtin)
+			assert.Equal(t, string(expectedSRCINFO), srcinfo)
+		})
+	}
+}
+
+func TestTemplate_Builtin(t *testing.T) {
+	pkg := PkgBuild{
+		CliName:         "pkg",
+		Maintainers:     []string{"Fuad Daoud <aur@fuad-daoud.com>"},
+		Pkgname:         "pkg-bin",
+		Version:         "0.1.4",
+		Pkgrel:          "1",
+		Description:     "Some single line description",
+		Url:             "https://github.com/fuad-daoud/pkg",
+		Arch:            []string{"x86_64"},
+		Licence:         []string{"MIT"},
+		Source_x86_64:   []string{"pkg-bin-0.1.4-x86_64::https://github.com/fuad-daoud/pkg/releases/download/v0.1.4/pkg.tar.gz"},
+		Checksum_x86_64: []string{"CHECKSUM1"},
+	}
+
+	tests := []struct {
+		name        string
+		override    string
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:     "bin-archive",
+			contains: []string{"package() {\n    install -Dm755 \"$srcdir/pkg\" \"$pkgdir/usr/bin/pkg\"", "/usr/share/licenses/$pkgname/LICENSE"},
+		},
+		{
+			name:     "appimage",
+			contains: []string{"options=('!strip')", "$pkgdir/opt/$pkgname/$pkgname.AppImage", "ln -s \"/opt/$pkgname/$pkgname.AppImage\" \"$pkgdir/usr/bin/pkg\""},
+		},
+		{
+			name:        "source-make",
+			contains:    []string{"build() {", "make DESTDIR=\"$pkgdir\" PREFIX=/usr install"},
+			notContains: []string{"prepare() {"},
+		},
+		{
+			name:        "bin-single",
+			override:    "testdata/override.tmpl",
+			contains:    []string{"# Packaged by release-aur\n\npkgname=pkg-bin", "\n\npackage() {\n    install -Dm755 \"$srcdir/$pkgname-$pkgver-$CARCH\" \"$pkgdir/usr/bin/pkg\"\n    install -Dm644 completions/pkg.bash"},
+			notContains: []string{"#Maintainer", "if [ \"$CARCH\" = \"x86_64\" ]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builtin := pkg
+			builtin.templateName = tt.name
+			builtin.templateOverride = tt.override
+
+			pkgbuild, _, err := builtin.template()
+
+			assert.NoError(t, err)
+			assert.Contains(t, pkgbuild, "pkgname=pkg-bin\npkgver=0.1.4\npkgrel=1\n")
+			for _, expected := range tt.contains {
+				assert.Contains(t, pkgbuild, expected)
+			}
+			for _, unexpected := range tt.notContains {
+				assert.NotContains(t, pkgbuild, unexpected)
+			}
+		})
+	}
+}
+
+func TestTemplate_CustomPathTakesPrecedence(t *testing.T) {
+	pkg := PkgBuild{
+		Pkgname:              "pkg-bin",
+		Pkgrel:               "2",
+		templateName:         "appimage",
+		pkgbuildTemplatePath: "testdata/custom.tmpl",
+		srcInfoTemplatePath:  "testdata/custom.tmpl",
+	}
+
+	pkgbuild, srcinfo, err := pkg.template()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "pkgname=pkg-bin\npkgrel=2\n", pkgbuild)
+	assert.Equal(t, "pkgname=pkg-bin\npkgrel=2\n", srcinfo)
+}
+
+func TestTemplatePath(t *testing.T) {
+	actionPath := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(actionPath, "templates"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(actionPath, "templates", "custom.tmpl"), []byte(""), 0644))
+
+	assert.Equal(t, "testdata/extra.tmpl", templatePath("testdata/extra.tmpl", actionPath))
+	assert.Equal(t, filepath.Join(actionPath, "templates", "custom.tmpl"), templatePath("templates/custom.tmpl", actionPath))
+	assert.Equal(t, "missing.tmpl", templatePath("missing.tmpl", actionPath))
+	assert.Equal(t, "templates/custom.tmpl", templatePath("templates/custom.tmpl", ""))
+	assert.Empty(t, templatePath("", actionPath))
+}
+
+func TestTemplate_Extra(t *testing.T) {
+	pkg := PkgBuild{
+		Extra:                map[string]string{"Completions": "completions/pkg.bash"},
+		pkgbuildTemplatePath: "testdata/extra.tmpl",
+	}
+
+	pkgbuild, _, err := pkg.template()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "completions=completions/pkg.bash\nshell=bash\ndocs=none\n", pkgbuild)
+
+	// an unset value is empty, so it can be optional
+	pkg.Extra = map[string]string{"Man": "pkg.1", "Docs": "README.md"}
+	pkgbuild, _, err = pkg.template()
+	assert.NoError(t, err)
+	assert.Equal(t, "completions=\nshell=bash\nman=pkg.1\ndocs=README.md\n", pkgbuild)
+}
+
+func TestTemplate_Install(t *testing.T) {
+	pkg := PkgBuild{
+		Maintainers: []string{"Fuad Daoud <aur@fuad-daoud.com>"},
+		Pkgname:     "pkg-bin",
+		Version:     "0.1.4",
+		Pkgrel:      "1",
+		Arch:        []string{"x86_64"},
+		Install:     "pkg-bin.install",
+	}
+
+	for _, name := range []string{"bin-single", "source-go"} {
+		pkg.templateName = name
+		pkgbuild, srcinfo, err := pkg.template()
+		assert.NoError(t, err)
+		assert.Contains(t, pkgbuild, "conflicts=()\ninstall=pkg-bin.install\n")
+		assert.Contains(t, srcinfo, "\turl = \n\tinstall = pkg-bin.install\n\tarch = x86_64\n")
+	}
+}
+
+func TestTemplate_BinSource(t *testing.T) {
+	pkg := PkgBuild{
+		Pkgname:         "pkg-bin",
+		Version:         "0.1.4",
+		Pkgrel:          "1",
+		Arch:            []string{"x86_64"},
+		Source:          []string{"pkg.service"},
+		Checksum:        []string{"CHECKSUM0"},
+		Source_x86_64:   []string{"https://example.com/pkg"},
+		Checksum_x86_64: []string{"CHECKSUM1"},
+	}
+
+	pkgbuild, srcinfo, err := pkg.template()
+
+	assert.NoError(t, err)
+	assert.Contains(t, pkgbuild, "conflicts=()\nsource=(\n\"pkg.service\"\n)\n\nsha256sums=(\n'CHECKSUM0'\n)\n\nsource_x86_64=(\n")
+	assert.Contains(t, srcinfo, "\tsource = pkg.service\n\tsha256sums = CHECKSUM0\n")
+}
+
+func TestTemplate_NoX86_64Sources(t *testing.T) {
+	pkg := PkgBuild{
+		Pkgname:          "pkg-bin",
+		Version:          "0.1.4",
+		Pkgrel:           "1",
+		Arch:             []string{"aarch64"},
+		Source_aarch64:   []string{"https://example.com/pkg-arm64"},
+		Checksum_aarch64: []string{"CHECKSUM2"},
+	}
+
+	pkgbuild, _, err := pkg.template()
+
+	assert.NoError(t, err)
+	assert.Contains(t, pkgbuild, "conflicts=()\nsource_aarch64=(\n\"https://example.com/pkg-arm64\"\n)\n\nsha256sums_aarch64=(\n'CHECKSUM2'\n)\n")
+	assert.NotContains(t, pkgbuild, "_x86_64=(")
+}
+
+func TestTemplate_Arrays(t *testing.T) {
+	pkg := PkgBuild{
+		Pkgname:      "pkg-bin",
+		Version:      "0.1.4",
+		Pkgrel:       "1",
+		Arch:         []string{"x86_64"},
+		Licence:      []string{"MIT"},
+		Groups:       []string{"pkg-tools"},
+		Checkdepends: []string{"bats"},
+		Optdepends:   []string{"git: clone repositories"},
+		Replaces:     []string{"old-pkg"},
+		Backup:       []string{"etc/pkg/config.toml"},
+		Options:      []string{"!strip", "!debug"},
+		Install:      "pkg-bin.install",
+		Changelog:    "CHANGELOG.md",
+		Noextract:    []string{"pkg.tar.gz"},
+	}
+
+	for _, name := range []string{"bin-single", "source-go", "appimage"} {
+		t.Run(name, func(t *testing.T) {
+			builtin := pkg
+			builtin.templateName = name
+			pkgbuild, srcinfo, err := builtin.template()
+
+			assert.NoError(t, err)
+			assert.Contains(t, pkgbuild, "license=('MIT')\ngroups=('pkg-tools')\n")
+			assert.Contains(t, pkgbuild, "checkdepends=('bats')\noptdepends=('git: clone repositories')\n")
+			assert.Contains(t, pkgbuild, "conflicts=()\nreplaces=('old-pkg')\nbackup=('etc/pkg/config.toml')\noptions=('!strip' '!debug')\ninstall=pkg-bin.install\nchangelog=CHANGELOG.md\nnoextract=('pkg.tar.gz')\n")
+			assert.Equal(t, 1, strings.Count(pkgbuild, "options="))
+			assert.Contains(t, srcinfo, "\tinstall = pkg-bin.install\n\tchangelog = CHANGELOG.md\n\tarch = x86_64\n\tgroups = pkg-tools\n\tlicense = MIT\n\tcheckdepends = bats\n")
+			assert.Contains(t, srcinfo, "\toptdepends = git: clone repositories\n")
+			assert.Contains(t, srcinfo, "\treplaces = old-pkg\n\tnoextract = pkg.tar.gz\n\toptions = !strip\n\toptions = !debug\n\tbackup = etc/pkg/config.toml\n")
 		})
 	}
 }
 
+func TestTemplateInstall(t *testing.T) {
+	tests := []struct {
+		name     string
+		pkg      PkgBuild
+		expected string
+	}{
+		{
+			name:     "no install file",
+			pkg:      PkgBuild{PostInstall: "echo ignored"},
+			expected: "",
+		},
+		{
+			name:     "post_install",
+			pkg:      PkgBuild{Install: "pkg.install", PostInstall: "echo 'Run: systemctl --user enable pkg.service'"},
+			expected: "post_install() {\n    echo 'Run: systemctl --user enable pkg.service'\n}\n",
+		},
+		{
+			name:     "post_upgrade and pre_remove",
+			pkg:      PkgBuild{Install: "pkg.install", PostUpgrade: "echo upgraded", PreRemove: "echo removing\nrm -f /tmp/pkg"},
+			expected: "post_upgrade() {\n    echo upgraded\n}\n\npre_remove() {\n    echo removing\n    rm -f /tmp/pkg\n}\n",
+		},
+		{
+			name:     "all functions",
+			pkg:      PkgBuild{Install: "pkg.install", PostInstall: "echo installed", PostUpgrade: "echo upgraded", PreRemove: "echo removing"},
+			expected: "post_install() {\n    echo installed\n}\n\npost_upgrade() {\n    echo upgraded\n}\n\npre_remove() {\n    echo removing\n}\n",
+		},
+		{
+			name:     "custom template",
+			pkg:      PkgBuild{Pkgname: "pkg", Version: "1.0.0", Install: "pkg.install", installTemplatePath: "testdata/install.tmpl"},
+			expected: "post_install() {\n    echo \"pkg 1.0.0 installed\"\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			install, err := tt.pkg.templateInstall()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, install)
+		})
+	}
+
+	_, err := PkgBuild{Install: "pkg.install", installTemplatePath: "./nonexistent.tmpl"}.templateInstall()
+	assert.Error(t, err)
+}
+
+func TestDefaultTemplateName(t *testing.T) {
+	assert.Equal(t, "bin-single", PkgBuild{}.defaultTemplateName())
+	assert.Equal(t, "bin-single", PkgBuild{Mode: "bin"}.defaultTemplateName())
+	assert.Equal(t, "source-rust", PkgBuild{Mode: "source", BuildPreset: "rust"}.defaultTemplateName())
+}
+
+func TestDumpTemplates(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "templates")
+
+	assert.NoError(t, dumpTemplates(dir))
+
+	for _, name := range append(builtinTemplateNames(), "base", "srcinfo", "install") {
+		dumped, err := os.ReadFile(filepath.Join(dir, name+".tmpl"))
+		assert.NoError(t, err)
+		embedded, err := builtinTemplates.ReadFile("templates/" + name + ".tmpl")
+		assert.NoError(t, err)
+		assert.Equal(t, string(embedded), string(dumped))
+	}
+
+	assert.ErrorContains(t, dumpTemplates(dir), "failed to create template")
+}
+
+func TestValidateTemplateName(t *testing.T) {
+	assert.Equal(t, []string{"appimage", "bin-archive", "bin-single", "source-go", "source-make", "source-rust"}, builtinTemplateNames())
+	assert.NoError(t, validateTemplateName(""))
+	assert.NoError(t, validateTemplateName("source-go"))
+
+	err := validateTemplateName("base")
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.EqualError(t, err, `Unknown Template "base", expected one of: appimage, bin-archive, bin-single, source-go, source-make, source-rust`)
+}
+
 func TestTemplate_Errors(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -91,9 +442,17 @@ func TestTemplate_Errors(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "empty template path",
+			name: "unknown built-in template",
+			pkg: PkgBuild{
+				templateName: "nope",
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing template override",
 			pkg: PkgBuild{
-				pkgbuildTemplatePath: "",
+				templateName:     "bin-single",
+				templateOverride: "./nonexistent.tmpl",
 			},
 			wantErr: true,
 		},
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	pkgrelAuto       = "auto"
+	pkgrelExplicit   = "explicit"
+	pkgrelAlwaysBump = "always-bump"
+	pkgrelReset      = "reset"
+)
+
+// pkgrelPattern is a pkgrel makepkg accepts, a release with an optional
+// sub-release like 1.1
+var pkgrelPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// pkgrelPolicy decides the pkgrel of the generated PKGBUILD from what the
+// comparator found on the AUR.
+//
+//   - auto: 1 for a new package or version, the published pkgrel + 1 otherwise
+//   - explicit=<n>: n, which has to be newer than the published pkgrel of the same version
+//   - always-bump: like auto, but an unchanged PKGBUILD is bumped too instead of failing
+//   - reset: 1, which like explicit=1 has to be newer than the published pkgrel of the same version
+type pkgrelPolicy struct {
+	mode   string
+	pkgrel string
+}
+
+func parsePkgrelPolicy(value string) pkgrelPolicy {
+	mode, pkgrel, _ := strings.Cut(value, "=")
+	if mode == "" {
+		mode = pkgrelAuto
+	}
+	return pkgrelPolicy{mode: mode, pkgrel: pkgrel}
+}
+
+func (policy pkgrelPolicy) validate() error {
+	switch policy.mode {
+	case "", pkgrelAuto, pkgrelAlwaysBump, pkgrelReset:
+		return nil
+	case pkgrelExplicit:
+		if !pkgrelPattern.MatchString(policy.pkgrel) {
+			return invalid("pkgrelPolicy", "PkgrelPolicy explicit needs a pkgrel like 2 or 1.1, got %q", policy.pkgrel)
+		}
+		return nil
+	}
+	return invalid("pkgrelPolicy", "Unknown PkgrelPolicy %q, expected auto, explicit=<n>, always-bump or reset", policy.mode)
+}
+
+// bumpsPublished reports whether a PKGBUILD identical to the published one is
+// bumped instead of refused.
+func (policy pkgrelPolicy) bumpsPublished() bool {
+	return policy.mode == pkgrelAlwaysBump
+}
+
+// String is the policy as it is configured, auto when it is not.
+func (policy pkgrelPolicy) String() string {
+	if policy.mode == "" {
+		return pkgrelAuto
+	}
+	if policy.mode == pkgrelExplicit {
+		return policy.mode + "=" + policy.pkgrel
+	}
+	return policy.mode
+}
+
+func (policy pkgrelPolicy) decide(remote remoteState) (string, error) {
+	sameVersion := remote.decision == decisionPkgrelBump
+	switch policy.mode {
+	case pkgrelExplicit, pkgrelReset:
+		pkgrel := policy.pkgrel
+		if policy.mode == pkgrelReset {
+			pkgrel = "1"
+		}
+		if sameVersion && rpmvercmp(pkgrel, remote.pkgrel) <= 0 {
+			return "", fmt.Errorf("%w: pkgrel %s is not newer than %s-%s on the AUR", ErrDowngrade, pkgrel, remote.version, remote.pkgrel)
+		}
+		return pkgrel, nil
+	}
+	if sameVersion {
+		return nextPkgrel(remote.pkgrel)
+	}
+	return "1", nil
+}
+
+// nextPkgrel bumps the release of pkgrel, a sub-release bumps to the next
+// release so 1.1 becomes 2.
+func nextPkgrel(pkgrel string) (string, error) {
+	release, _, _ := strings.Cut(pkgrel, ".")
+	number, err := strconv.Atoi(release)
+	if err != nil {
+		return "", fmt.Errorf("Couldn't parse pkgrel %s", pkgrel)
+	}
+	return strconv.Itoa(number + 1), nil
+}
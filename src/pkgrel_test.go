@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPkgrelPolicy_decide(t *testing.T) {
+	newVersion := remoteState{decision: decisionNewVersion, version: "0.9.0", pkgrel: "3"}
+	sameVersion := remoteState{decision: decisionPkgrelBump, version: "1.0.0", pkgrel: "2"}
+	subrelease := remoteState{decision: decisionPkgrelBump, version: "1.0.0", pkgrel: "2.1"}
+
+	tests := []struct {
+		name        string
+		policy      string
+		remote      remoteState
+		expected    string
+		errContains string
+	}{
+		{name: "auto new package", policy: "", remote: remoteState{decision: decisionNewPackage}, expected: "1"},
+		{name: "auto new version", policy: "auto", remote: newVersion, expected: "1"},
+		{name: "auto same version", policy: "auto", remote: sameVersion, expected: "3"},
+		{name: "auto after a sub-release", policy: "auto", remote: subrelease, expected: "3"},
+		{name: "always-bump same version", policy: "always-bump", remote: sameVersion, expected: "3"},
+		{name: "always-bump new version", policy: "always-bump", remote: newVersion, expected: "1"},
+		{name: "reset new version", policy: "reset", remote: newVersion, expected: "1"},
+		{name: "reset after a sub-release of 0", policy: "reset", remote: remoteState{decision: decisionPkgrelBump, version: "1.0.0", pkgrel: "0.1"}, expected: "1"},
+		{name: "reset same version", policy: "reset", remote: sameVersion, errContains: "pkgrel 1 is not newer than 1.0.0-2 on the AUR"},
+		{name: "explicit new version", policy: "explicit=1", remote: newVersion, expected: "1"},
+		{name: "explicit sub-release", policy: "explicit=2.1", remote: sameVersion, expected: "2.1"},
+		{name: "explicit newer sub-release", policy: "explicit=2.10", remote: subrelease, expected: "2.10"},
+		{name: "explicit same as remote", policy: "explicit=2", remote: sameVersion, errContains: "pkgrel 2 is not newer than 1.0.0-2 on the AUR"},
+		{name: "explicit older than remote", policy: "explicit=1.5", remote: sameVersion, errContains: "not newer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkgrel, err := parsePkgrelPolicy(tt.policy).decide(tt.remote)
+			if tt.errContains != "" {
+				assert.ErrorIs(t, err, ErrDowngrade)
+				assert.ErrorContains(t, err, tt.errContains)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, pkgrel)
+		})
+	}
+}
+
+func TestPkgrelPolicy_validate(t *testing.T) {
+	for _, policy := range []string{"", "auto", "always-bump", "reset", "explicit=2", "explicit=1.1"} {
+		assert.NoError(t, parsePkgrelPolicy(policy).validate(), policy)
+	}
+
+	err := parsePkgrelPolicy("explicit").validate()
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.EqualError(t, err, `PkgrelPolicy explicit needs a pkgrel like 2 or 1.1, got ""`)
+
+	assert.ErrorContains(t, parsePkgrelPolicy("explicit=1-2").validate(), "needs a pkgrel")
+	assert.ErrorContains(t, parsePkgrelPolicy("bump").validate(), `Unknown PkgrelPolicy "bump"`)
+}
+
+func TestPkgrelPolicy_String(t *testing.T) {
+	for policy, expected := range map[string]string{"": "auto", "always-bump": "always-bump", "reset": "reset", "explicit=1.1": "explicit=1.1"} {
+		assert.Equal(t, expected, parsePkgrelPolicy(policy).String(), policy)
+	}
+	assert.Equal(t, "auto", pkgrelPolicy{}.String())
+}
+
+func TestNextPkgrel(t *testing.T) {
+	for pkgrel, expected := range map[string]string{"1": "2", "9": "10", "1.1": "2", "3.10": "4"} {
+		next, err := nextPkgrel(pkgrel)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, next, pkgrel)
+	}
+
+	_, err := nextPkgrel("abc")
+	assert.ErrorContains(t, err, "Couldn't parse pkgrel abc")
+}
+
+func TestDefaultCompareWithRemote_AlwaysBump(t *testing.T) {
+	localPKGBUILD := "pkgname=test\npkgver=1.0.0\npkgrel=1.1\nsha256sums_x86_64=('abc123')"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "rpc") {
+			w.Write([]byte(`{"resultcount":1,"results":[{"Name":"test","Version":"1.0.0-1.1"}]}`))
+		} else if strings.Contains(r.URL.Path, "PKGBUILD") {
+			w.Write([]byte(localPKGBUILD))
+		}
+	}))
+	defer server.Close()
+
+	pkgbuild := PkgBuild{
+		Pkgname:         "test",
+		Version:         "1.0.0",
+		Checksum_x86_64: []string{"abc123"},
+		pkgrelPolicy:    parsePkgrelPolicy("always-bump"),
+	}
+
+	remote, err := defaultCompareWithRemote(context.Background(), DummyClient(server), pkgbuild, localPKGBUILD)
+
+	assert.NoError(t, err)
+	assert.Equal(t, decisionPkgrelBump, remote.decision)
+	assert.Equal(t, "1.1", remote.pkgrel)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/fuad-daoud/release-aur/src/parser"
+)
+
+// githubRelease is the subset of the GitHub releases API response used for asset discovery.
+type githubRelease struct {
+	TagName string         `json:"tag_name"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+type releaseAsset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+var defaultAssetPatterns = map[string]string{
+	"x86_64":  "*linux-amd64*",
+	"aarch64": "*linux-arm64*",
+}
+
+// checksumsFilePatterns match the checksums files published next to release artifacts.
+var checksumsFilePatterns = []string{
+	"checksums.txt",
+	"*_checksums.txt",
+	"*-checksums.txt",
+	"SHA256SUMS",
+	"sha256sums.txt",
+}
+
+// matchAsset matches an asset name against a glob pattern, or against a regular
+// expression when the pattern is prefixed with "re:".
+func matchAsset(pattern, name string) (bool, error) {
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		return regexp.MatchString(expr, name)
+	}
+	return path.Match(pattern, name)
+}
+
+func isChecksumsFile(name string) bool {
+	for _, pattern := range checksumsFilePatterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// auxiliarySuffixes end the signatures, certificates, attestations and SBOMs
+// released next to an artifact.
+var auxiliarySuffixes = []string{
+	".sig", ".asc", ".sha256", ".pem", ".cert", ".crt", ".pub",
+	".sigstore", ".sigstore.json", ".bundle", ".intoto.jsonl",
+	".sbom", ".sbom.json", ".spdx.json",
+}
+
+// archiveSuffixes are the formats an artifact is released in, an asset named
+// like another one plus one of them is a different artifact.
+var archiveSuffixes = []string{
+	".tar", ".tar.gz", ".tgz", ".tar.xz", ".txz", ".tar.bz2", ".tbz", ".tar.zst", ".zip", ".gz", ".xz", ".zst",
+	".AppImage", ".deb", ".rpm",
+}
+
+// isAuxiliaryAsset reports assets that sit next to an artifact, like its signature.
+func isAuxiliaryAsset(name string) bool {
+	for _, suffix := range auxiliarySuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return isChecksumsFile(name)
+}
+
+// isCompanionAsset reports whether asset is named like another of assets plus
+// a suffix that is no archive format, like an attestation with an unusual suffix.
+func isCompanionAsset(asset releaseAsset, assets []releaseAsset) bool {
+	for _, artifact := range assets {
+		suffix, ok := strings.CutPrefix(asset.Name, artifact.Name+".")
+		if ok && !slices.Contains(archiveSuffixes, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func loadRelease(get func(string) ([]byte, error), location string) (githubRelease, error) {
+	var body []byte
+	var err error
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		body, err = get(location)
+	} else {
+		body, err = os.ReadFile(location)
+	}
+	if err != nil {
+		return githubRelease{}, fmt.Errorf("failed to read release manifest %v: %w", location, err)
+	}
+
+	var release githubRelease
+	if err := json.Unmarshal(body, &release); err != nil {
+		return githubRelease{}, fmt.Errorf("Could not unmarshal the release manifest: %w", err)
+	}
+	return release, nil
+}
+
+func findAsset(assets []releaseAsset, pattern string) (releaseAsset, error) {
+	var found []releaseAsset
+	for _, asset := range assets {
+		if isAuxiliaryAsset(asset.Name) {
+			continue
+		}
+		matched, err := matchAsset(pattern, asset.Name)
+		if err != nil {
+			return releaseAsset{}, fmt.Errorf("invalid asset pattern %q: %w", pattern, err)
+		}
+		if matched {
+			found = append(found, asset)
+		}
+	}
+	found = slices.DeleteFunc(slices.Clone(found), func(asset releaseAsset) bool {
+		return isCompanionAsset(asset, found)
+	})
+	if len(found) == 0 {
+		return releaseAsset{}, fmt.Errorf("no release asset matches %q", pattern)
+	}
+	if len(found) > 1 {
+		names := make([]string, len(found))
+		for i, asset := range found {
+			names[i] = asset.Name
+		}
+		return releaseAsset{}, fmt.Errorf("pattern %q matches more than one release asset: %v", pattern, names)
+	}
+	return found[0], nil
+}
+
+// discoverReleaseAssets fills the per architecture sources from the release manifest,
+// and switches to the published checksums file when the release has one and no
+// checksums_url was configured.
+func (pkgbuild *PkgBuild) discoverReleaseAssets(get func(string) ([]byte, error)) error {
+	release, err := loadRelease(get, pkgbuild.releaseManifest)
+	if err != nil {
+		return err
+	}
+	slog.Info("Discovering sources from release", "tag", release.TagName, "assets", len(release.Assets))
+
+	for _, arch := range pkgbuild.Arch {
+		pattern, ok := pkgbuild.assetPatterns[arch]
+		if !ok {
+			return fmt.Errorf("no asset pattern configured for arch %s", arch)
+		}
+		asset, err := findAsset(release.Assets, pattern)
+		if err != nil {
+			return fmt.Errorf("%s: %w", arch, err)
+		}
+
+		source := fmt.Sprintf("%s-%s-%s::%s", pkgbuild.Pkgname, pkgbuild.Version, arch, asset.BrowserDownloadURL)
+		slog.Info("Matched release asset", "arch", arch, "asset", asset.Name)
+		switch arch {
+		case "x86_64":
+			pkgbuild.Source_x86_64 = []string{source}
+		case "aarch64":
+			pkgbuild.Source_aarch64 = []string{source}
+		default:
+			return fmt.Errorf("unsupported arch %s", arch)
+		}
+	}
+
+	if len(pkgbuild.checksumsURLs) != 0 {
+		return nil
+	}
+	for _, asset := range release.Assets {
+		if isChecksumsFile(asset.Name) {
+			slog.Info("Using published checksums file", "asset", asset.Name)
+			pkgbuild.checksumCalculator = parser.NewChecksumsFileCalculator([]string{asset.BrowserDownloadURL}, pkgbuild.verifyChecksums)
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchAsset(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		asset    string
+		expected bool
+		wantErr  bool
+	}{
+		{name: "glob match", pattern: "*linux-amd64*", asset: "pkg-linux-amd64.tar.gz", expected: true},
+		{name: "glob no match", pattern: "*linux-amd64*", asset: "pkg-linux-arm64", expected: false},
+		{name: "regex match", pattern: `re:^pkg-.*-(x86_64|amd64)$`, asset: "pkg-linux-x86_64", expected: true},
+		{name: "regex no match", pattern: `re:^pkg-.*-amd64$`, asset: "pkg-linux-amd64.sig", expected: false},
+		{name: "invalid glob", pattern: "[", asset: "pkg", wantErr: true},
+		{name: "invalid regex", pattern: "re:(", asset: "pkg", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, err := matchAsset(tt.pattern, tt.asset)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, matched)
+		})
+	}
+}
+
+func TestFindAsset(t *testing.T) {
+	assets := []releaseAsset{
+		{Name: "pkg-linux-amd64"},
+		{Name: "pkg-linux-amd64.sig"},
+		{Name: "pkg-linux-arm64"},
+		{Name: "pkg-linux-arm64.tar.gz"},
+		{Name: "checksums.txt"},
+	}
+
+	asset, err := findAsset(assets, "*linux-amd64*")
+	assert.NoError(t, err)
+	assert.Equal(t, "pkg-linux-amd64", asset.Name)
+
+	_, err = findAsset(assets, "*linux-arm64*")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "more than one release asset")
+
+	_, err = findAsset(assets, "*windows*")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no release asset matches")
+
+	_, err = findAsset(assets, "[")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid asset pattern")
+}
+
+func TestFindAsset_SkipsCompanions(t *testing.T) {
+	assets := []releaseAsset{
+		{Name: "tool-linux-amd64"},
+		{Name: "tool-linux-amd64.sigstore.json"},
+		{Name: "tool-linux-amd64.sigstore"},
+		{Name: "tool-linux-amd64.bundle"},
+		{Name: "tool-linux-amd64.cert"},
+		{Name: "tool-linux-amd64.crt"},
+		{Name: "tool-linux-amd64.pub"},
+		{Name: "tool-linux-amd64.spdx.json"},
+		{Name: "tool-linux-amd64.sbom.json"},
+		{Name: "tool-linux-amd64.attestation"},
+		{Name: "tool-linux-arm64.tar.gz"},
+		{Name: "tool-linux-arm64.tar.gz.provenance"},
+	}
+
+	asset, err := findAsset(assets, "*linux-amd64*")
+	assert.NoError(t, err)
+	assert.Equal(t, "tool-linux-amd64", asset.Name)
+
+	asset, err = findAsset(assets, "*linux-arm64*")
+	assert.NoError(t, err)
+	assert.Equal(t, "tool-linux-arm64.tar.gz", asset.Name)
+}
+
+func TestDiscoverReleaseAssets(t *testing.T) {
+	t.Run("local manifest", func(t *testing.T) {
+		pkgbuild := NewPkgBuild()
+		pkgbuild.Pkgname = "pkg-bin"
+		pkgbuild.Version = "0.1.4"
+		pkgbuild.Arch = []string{"x86_64", "aarch64"}
+		pkgbuild.releaseManifest = "testdata/release.json"
+		pkgbuild.assetPatterns = defaultAssetPatterns
+
+		err := pkgbuild.discoverReleaseAssets(func(string) ([]byte, error) {
+			return nil, fmt.Errorf("should not be called")
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"pkg-bin-0.1.4-x86_64::https://github.com/fuad-daoud/pkg/releases/download/v0.1.4/pkg-linux-amd64"}, pkgbuild.Source_x86_64)
+		assert.Equal(t, []string{"pkg-bin-0.1.4-aarch64::https://github.com/fuad-daoud/pkg/releases/download/v0.1.4/pkg-linux-arm64"}, pkgbuild.Source_aarch64)
+
+		checksums, err := pkgbuild.checksumCalculator(func(url string) ([]byte, error) {
+			assert.Equal(t, "https://github.com/fuad-daoud/pkg/releases/download/v0.1.4/checksums.txt", url)
+			return []byte("ba62160a8721ea41c112adc3cd369e1c7abb9d1c03d2bd89d13740b420cc1cc6  pkg-linux-amd64\n"), nil
+		}, nil, pkgbuild.Source_x86_64)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"ba62160a8721ea41c112adc3cd369e1c7abb9d1c03d2bd89d13740b420cc1cc6"}, checksums)
+	})
+
+	t.Run("manifest from the releases API", func(t *testing.T) {
+		manifest, err := os.ReadFile("testdata/release.json")
+		assert.NoError(t, err)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(manifest)
+		}))
+		defer server.Close()
+
+		pkgbuild := NewPkgBuild()
+		pkgbuild.Pkgname = "pkg-bin"
+		pkgbuild.Version = "0.1.4"
+		pkgbuild.Arch = []string{"x86_64"}
+		pkgbuild.releaseManifest = server.URL + "/repos/fuad-daoud/pkg/releases/tags/v0.1.4"
+		pkgbuild.assetPatterns = map[string]string{"x86_64": `re:-amd64$`}
+
+		err = pkgbuild.discoverReleaseAssets(func(url string) ([]byte, error) {
+			return DummyClient(server).Get(context.Background(), url)
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"pkg-bin-0.1.4-x86_64::https://github.com/fuad-daoud/pkg/releases/download/v0.1.4/pkg-linux-amd64"}, pkgbuild.Source_x86_64)
+		assert.Empty(t, pkgbuild.Source_aarch64)
+	})
+
+	t.Run("configured checksums_url wins", func(t *testing.T) {
+		pkgbuild := NewPkgBuild()
+		pkgbuild.Arch = []string{"x86_64"}
+		pkgbuild.releaseManifest = "testdata/release.json"
+		pkgbuild.assetPatterns = defaultAssetPatterns
+		pkgbuild.checksumsURLs = []string{"https://example.com/SHA256SUMS"}
+		pkgbuild.checksumCalculator = nil
+
+		err := pkgbuild.discoverReleaseAssets(nil)
+
+		assert.NoError(t, err)
+		assert.Nil(t, pkgbuild.checksumCalculator)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		pkgbuild := NewPkgBuild()
+		pkgbuild.Arch = []string{"x86_64"}
+		pkgbuild.assetPatterns = defaultAssetPatterns
+
+		pkgbuild.releaseManifest = "testdata/missing.json"
+		err := pkgbuild.discoverReleaseAssets(nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read release manifest")
+
+		pkgbuild.releaseManifest = "testdata/PKGBUILD"
+		err = pkgbuild.discoverReleaseAssets(nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unmarshal")
+
+		pkgbuild.releaseManifest = "testdata/release.json"
+		pkgbuild.Arch = []string{"riscv64"}
+		err = pkgbuild.discoverReleaseAssets(nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "no asset pattern configured for arch riscv64")
+
+		pkgbuild.Arch = []string{"x86_64"}
+		pkgbuild.assetPatterns = map[string]string{"x86_64": "*windows*"}
+		err = pkgbuild.discoverReleaseAssets(nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "x86_64: no release asset matches")
+	})
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/fuad-daoud/release-aur/src/parser"
+)
+
+// reportVersion is bumped whenever a field of the report changes meaning or is
+// removed, adding fields keeps the version.
+const reportVersion = 2
+
+// runReport describes the outcome of generate for the steps that run after it.
+type runReport struct {
+	Version  int    `json:"version"`
+	Pkgname  string `json:"pkgname"`
+	Pkgver   string `json:"pkgver"`
+	Pkgrel   string `json:"pkgrel"`
+	Decision string `json:"decision"`
+	// PkgrelPolicy is the pkgrel policy Pkgrel was decided by
+	PkgrelPolicy  string         `json:"pkgrel_policy,omitempty"`
+	RemoteVersion string         `json:"remote_version,omitempty"`
+	Sources       []reportSource `json:"sources"`
+	Files         reportFiles    `json:"files"`
+	Warnings      []string       `json:"warnings"`
+	// UpToDate is set when the AUR already has the same PKGBUILD, no new files
+	// were needed and Files only point to the published ones if they were written
+	UpToDate bool `json:"up_to_date"`
+	// Diff is the line diff from the published PKGBUILD when it was compared
+	Diff string `json:"diff,omitempty"`
+}
+
+type reportSource struct {
+	Arch   string `json:"arch,omitempty"`
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Sha256 string `json:"sha256"`
+}
+
+type reportFiles struct {
+	PKGBUILD  string `json:"pkgbuild"`
+	SRCINFO   string `json:"srcinfo"`
+	Install   string `json:"install,omitempty"`
+	Changelog string `json:"changelog,omitempty"`
+	// LocalSources are the copies of the local sources next to the PKGBUILD
+	LocalSources []string `json:"local_sources,omitempty"`
+}
+
+func (pkgbuild PkgBuild) newReport(remote remoteState, PKGBUILD string, files reportFiles) runReport {
+	report := runReport{
+		Version:      reportVersion,
+		Pkgname:      pkgbuild.Pkgname,
+		Pkgver:       pkgbuild.Version,
+		Pkgrel:       pkgbuild.Pkgrel,
+		Decision:     remote.decision,
+		PkgrelPolicy: pkgbuild.pkgrelPolicy.String(),
+		UpToDate:     remote.decision == decisionUpToDate,
+		Sources:      []reportSource{},
+		Files:        reportFiles{PKGBUILD: absPath(files.PKGBUILD), SRCINFO: absPath(files.SRCINFO), Install: absPath(files.Install), Changelog: absPath(files.Changelog)},
+		Warnings:     append(append([]string{}, pkgbuild.warnings...), remote.warnings...),
+	}
+	for _, path := range files.LocalSources {
+		report.Files.LocalSources = append(report.Files.LocalSources, absPath(path))
+	}
+	if remote.pkgbuild != "" {
+		report.Diff = lineDiff(remote.pkgbuild, PKGBUILD)
+	}
+	if remote.version != "" {
+		report.RemoteVersion = remote.version + "-" + remote.pkgrel
+	}
+	for _, arch := range []struct {
+		name      string
+		sources   []string
+		checksums []string
+	}{
+		{"", pkgbuild.Source, pkgbuild.Checksum},
+		{"x86_64", pkgbuild.Source_x86_64, pkgbuild.Checksum_x86_64},
+		{"aarch64", pkgbuild.Source_aarch64, pkgbuild.Checksum_aarch64},
+	} {
+		for i, source := range arch.sources {
+			entry := reportSource{Arch: arch.name, Name: sourceFilename(source)}
+			_, entry.URL = parser.SplitSource(source)
+			if i < len(arch.checksums) {
+				entry.Sha256 = arch.checksums[i]
+			}
+			report.Sources = append(report.Sources, entry)
+		}
+	}
+	return report
+}
+
+// batchReport is the report of a batch run, with the report of every package
+// that was generated and the error of every package that failed.
+type batchReport struct {
+	Version  int                `json:"version"`
+	Packages []batchReportEntry `json:"packages"`
+}
+
+type batchReportEntry struct {
+	Pkgname string     `json:"pkgname"`
+	Error   string     `json:"error,omitempty"`
+	Report  *runReport `json:"report,omitempty"`
+}
+
+func newBatchReport(results []batchResult) batchReport {
+	report := batchReport{Version: reportVersion, Packages: make([]batchReportEntry, len(results))}
+	for i, result := range results {
+		report.Packages[i] = batchReportEntry{Pkgname: result.Pkgname, Report: result.Report}
+		if result.Err != nil {
+			report.Packages[i].Error = result.Err.Error()
+		}
+	}
+	return report
+}
+
+func writeReport(path string, report any) error {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, string(content)+"\n")
+}
+
+// lineDiff returns every line of a and b prefixed with "-", "+" or " " like a
+// unified diff without hunk headers, it is empty when both are the same.
+func lineDiff(a, b string) string {
+	if a == b {
+		return ""
+	}
+	x := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	y := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+
+	// common[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	common := make([][]int, len(x)+1)
+	for i := range common {
+		common[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var diff strings.Builder
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			diff.WriteString(" " + x[i] + "\n")
+			i, j = i+1, j+1
+		case j == len(y) || (i < len(x) && common[i+1][j] >= common[i][j+1]):
+			diff.WriteString("-" + x[i] + "\n")
+			i++
+		default:
+			diff.WriteString("+" + y[j] + "\n")
+			j++
+		}
+	}
+	return diff.String()
+}
+
+func absPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateWith_Report(t *testing.T) {
+	output := t.TempDir()
+	pkg := &PkgBuild{
+		CliName:        "test",
+		Maintainers:    []string{"User"},
+		Pkgname:        "test-bin",
+		Version:        "1.0.0",
+		Pkgrel:         "1",
+		Description:    "Test",
+		Url:            "https://example.com",
+		Arch:           []string{"x86_64", "aarch64"},
+		Licence:        []string{"MIT"},
+		Source:         []string{"LICENSE::https://example.com/LICENSE"},
+		Source_x86_64:  []string{"test-1.0.0-x86_64::https://example.com/test-amd64"},
+		Source_aarch64: []string{"https://example.com/test-arm64"},
+		outputPath:     output + "/",
+		reportPath:     filepath.Join(output, "report", "report.json"),
+		comparator: func(context.Context, Client, PkgBuild, string) (remoteState, error) {
+			return remoteState{decision: decisionPkgrelBump, version: "1.0.0", pkgrel: "2"}, nil
+		},
+		checksumCalculator: func(get func(string) ([]byte, error), sums func(string) (string, bool), sources []string) ([]string, error) {
+			checksums := make([]string, len(sources))
+			for i, source := range sources {
+				checksums[i] = "sha-" + sourceFilename(source)
+			}
+			return checksums, nil
+		},
+	}
+
+	_, err := pkg.generateWith(context.Background(), Client{})
+	assert.NoError(t, err)
+
+	content, err := os.ReadFile(pkg.reportPath)
+	assert.NoError(t, err)
+	var report runReport
+	assert.NoError(t, json.Unmarshal(content, &report))
+	assert.Equal(t, runReport{
+		Version:       reportVersion,
+		Pkgname:       "test-bin",
+		Pkgver:        "1.0.0",
+		Pkgrel:        "3",
+		Decision:      decisionPkgrelBump,
+		PkgrelPolicy:  "auto",
+		RemoteVersion: "1.0.0-2",
+		Sources: []reportSource{
+			{Name: "LICENSE", URL: "https://example.com/LICENSE", Sha256: "sha-LICENSE"},
+			{Arch: "x86_64", Name: "test-1.0.0-x86_64", URL: "https://example.com/test-amd64", Sha256: "sha-test-1.0.0-x86_64"},
+			{Arch: "aarch64", Name: "test-arm64", URL: "https://example.com/test-arm64", Sha256: "sha-test-arm64"},
+		},
+		Files: reportFiles{
+			PKGBUILD: filepath.Join(output, "PKGBUILD"),
+			SRCINFO:  filepath.Join(output, ".SRCINFO"),
+		},
+		Warnings: []string{},
+	}, report)
+	assert.Equal(t, report, pkg.report)
+}
+
+func TestGenerateWith_Install(t *testing.T) {
+	output := t.TempDir()
+	pkg := &PkgBuild{
+		Pkgname:     "test-bin",
+		Version:     "1.0.0",
+		Pkgrel:      "1",
+		Arch:        []string{"x86_64"},
+		Install:     "test-bin.install",
+		PostInstall: "echo installed",
+		outputPath:  output + "/",
+		comparator: func(context.Context, Client, PkgBuild, string) (remoteState, error) {
+			return remoteState{decision: decisionNewPackage}, nil
+		},
+		checksumCalculator: func(get func(string) ([]byte, error), sums func(string) (string, bool), sources []string) ([]string, error) {
+			return []string{}, nil
+		},
+	}
+
+	PKGBUILD, err := pkg.generateWith(context.Background(), Client{})
+	assert.NoError(t, err)
+	assert.Contains(t, PKGBUILD, "install=test-bin.install\n")
+	assert.Equal(t, filepath.Join(output, "test-bin.install"), pkg.report.Files.Install)
+	assert.Equal(t, "post_install() {\n    echo installed\n}\n", readFile(t, pkg.report.Files.Install))
+}
+
+func TestGenerateWith_LocalSources(t *testing.T) {
+	dir := t.TempDir()
+	service := filepath.Join(dir, "test.service")
+	assert.NoError(t, os.WriteFile(service, []byte("[Service]\n"), 0644))
+	pkg := &PkgBuild{
+		Pkgname:      "test-bin",
+		Version:      "1.0.0",
+		Pkgrel:       "1",
+		Arch:         []string{"x86_64"},
+		localSources: []string{service},
+		outputPath:   filepath.Join(dir, "output") + "/",
+		comparator: func(context.Context, Client, PkgBuild, string) (remoteState, error) {
+			return remoteState{decision: decisionNewPackage}, nil
+		},
+		checksumCalculator: func(get func(string) ([]byte, error), sums func(string) (string, bool), sources []string) ([]string, error) {
+			return []string{}, nil
+		},
+	}
+
+	PKGBUILD, err := pkg.generateWith(context.Background(), Client{})
+	assert.NoError(t, err)
+	assert.Contains(t, PKGBUILD, "source=(\n\"test.service\"\n)\n\nsha256sums=(\n'40d8baaabac85ad8ac5a2ec40dd065b4ea0fb47c25ce3ddad4c290d22503c90f'\n)\n")
+	assert.Equal(t, []string{filepath.Join(dir, "output", "test.service")}, pkg.report.Files.LocalSources)
+	assert.Equal(t, "[Service]\n", readFile(t, pkg.report.Files.LocalSources[0]))
+}
+
+func TestGenerateWith_UpToDate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pkgbase = test-bin\n\tpkgver = 1.0.0\n\tpkgrel = 2\n"))
+	}))
+	defer server.Close()
+
+	for _, writeRemote := range []bool{false, true} {
+		output := t.TempDir()
+		pkg := &PkgBuild{
+			Pkgname:     "test-bin",
+			Version:     "1.0.0",
+			Pkgrel:      "1",
+			Arch:        []string{"x86_64"},
+			outputPath:  output + "/",
+			reportPath:  filepath.Join(output, "report.json"),
+			idempotent:  true,
+			writeRemote: writeRemote,
+			comparator: func(context.Context, Client, PkgBuild, string) (remoteState, error) {
+				return remoteState{decision: decisionUpToDate, version: "1.0.0", pkgrel: "2", pkgbuild: "pkgname=test-bin\npkgrel=2\n"}, nil
+			},
+			checksumCalculator: func(get func(string) ([]byte, error), sums func(string) (string, bool), sources []string) ([]string, error) {
+				return []string{}, nil
+			},
+		}
+
+		PKGBUILD, err := pkg.generateWith(context.Background(), DummyClient(server))
+		assert.NoError(t, err)
+		assert.Equal(t, "pkgname=test-bin\npkgrel=2\n", PKGBUILD)
+		assert.FileExists(t, pkg.reportPath)
+		assert.True(t, pkg.report.UpToDate)
+		assert.Equal(t, "2", pkg.report.Pkgrel)
+		assert.Empty(t, pkg.report.Diff)
+
+		if !writeRemote {
+			assert.NoFileExists(t, filepath.Join(output, "PKGBUILD"))
+			assert.Equal(t, reportFiles{}, pkg.report.Files)
+			continue
+		}
+		assert.Equal(t, "pkgname=test-bin\npkgrel=2\n", readFile(t, pkg.report.Files.PKGBUILD))
+		assert.Equal(t, "pkgbase = test-bin\n\tpkgver = 1.0.0\n\tpkgrel = 2\n", readFile(t, pkg.report.Files.SRCINFO))
+	}
+}
+
+func TestNewReport_NewPackage(t *testing.T) {
+	pkg := PkgBuild{Pkgname: "test-bin", Version: "1.0.0", Pkgrel: "1"}
+	report := pkg.newReport(remoteState{decision: decisionNewPackage}, "", reportFiles{PKGBUILD: "out/PKGBUILD", SRCINFO: "out/.SRCINFO"})
+
+	assert.Equal(t, decisionNewPackage, report.Decision)
+	assert.Empty(t, report.RemoteVersion)
+	assert.NotNil(t, report.Sources)
+	assert.True(t, filepath.IsAbs(report.Files.PKGBUILD))
+}
+
+func TestNewBatchReport(t *testing.T) {
+	report := newBatchReport([]batchResult{
+		{Pkgname: "first-bin", Report: &runReport{Version: reportVersion, Pkgname: "first-bin"}},
+		{Pkgname: "second-bin", Err: errors.New("boom")},
+	})
+
+	content, err := json.Marshal(report)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"version": 2,
+		"packages": [
+			{"pkgname": "first-bin", "report": {"version": 2, "pkgname": "first-bin", "pkgver": "", "pkgrel": "", "decision": "", "sources": null, "files": {"pkgbuild": "", "srcinfo": ""}, "warnings": null, "up_to_date": false}},
+			{"pkgname": "second-bin", "error": "boom"}
+		]
+	}`, string(content))
+}
+
+func TestNewReport_Diff(t *testing.T) {
+	pkg := PkgBuild{Pkgname: "test-bin", Version: "1.0.0", Pkgrel: "2"}
+	remote := remoteState{
+		decision: decisionPkgrelBump,
+		version:  "1.0.0",
+		pkgrel:   "1",
+		pkgbuild: "pkgname=test-bin\npkgver=1.0.0\npkgrel=1\ndepends=()\n",
+		warnings: []string{"test-bin is orphaned on the AUR"},
+	}
+	report := pkg.newReport(remote, "pkgname=test-bin\npkgver=1.0.0\npkgrel=2\ndepends=('git')\n", reportFiles{PKGBUILD: "PKGBUILD", SRCINFO: ".SRCINFO"})
+
+	assert.Equal(t, " pkgname=test-bin\n pkgver=1.0.0\n-pkgrel=1\n-depends=()\n+pkgrel=2\n+depends=('git')\n", report.Diff)
+	assert.Equal(t, []string{"test-bin is orphaned on the AUR"}, report.Warnings)
+}
+
+func TestLineDiff(t *testing.T) {
+	assert.Equal(t, "", lineDiff("a\nb\n", "a\nb\n"))
+	assert.Equal(t, " a\n+b\n c\n", lineDiff("a\nc", "a\nb\nc"))
+	assert.Equal(t, "-a\n b\n-c\n", lineDiff("a\nb\nc", "b"))
+}
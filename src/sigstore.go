@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fuad-daoud/release-aur/src/parser"
+)
+
+// Fulcio certificate extensions holding the OIDC issuer of the signing identity.
+var (
+	oidcIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidcIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+type sigstoreRawBytes struct {
+	RawBytes []byte `json:"rawBytes"`
+}
+
+// sigstoreBundle is the subset of the sigstore bundle (v0.1 to v0.3) used for offline verification.
+type sigstoreBundle struct {
+	MediaType            string `json:"mediaType"`
+	VerificationMaterial struct {
+		Certificate          *sigstoreRawBytes `json:"certificate"`
+		X509CertificateChain *struct {
+			Certificates []sigstoreRawBytes `json:"certificates"`
+		} `json:"x509CertificateChain"`
+		TlogEntries []sigstoreTlogEntry `json:"tlogEntries"`
+	} `json:"verificationMaterial"`
+	MessageSignature *struct {
+		MessageDigest struct {
+			Algorithm string `json:"algorithm"`
+			Digest    []byte `json:"digest"`
+		} `json:"messageDigest"`
+		Signature []byte `json:"signature"`
+	} `json:"messageSignature"`
+	DsseEnvelope *dsseEnvelope `json:"dsseEnvelope"`
+}
+
+// sigstoreTlogEntry is a Rekor entry, its signed entry timestamp is the log's
+// promise to include canonicalizedBody at integratedTime.
+type sigstoreTlogEntry struct {
+	LogIndex int64 `json:"logIndex,string"`
+	LogID    struct {
+		KeyID []byte `json:"keyId"`
+	} `json:"logId"`
+	IntegratedTime   int64 `json:"integratedTime,string"`
+	InclusionPromise *struct {
+		SignedEntryTimestamp []byte `json:"signedEntryTimestamp"`
+	} `json:"inclusionPromise"`
+	CanonicalizedBody []byte `json:"canonicalizedBody"`
+}
+
+// signedEntryTimestamp is what Rekor signs, the keys are in canonical JSON order.
+type signedEntryTimestamp struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+type dsseEnvelope struct {
+	Payload     []byte `json:"payload"`
+	PayloadType string `json:"payloadType"`
+	Signatures  []struct {
+		Sig []byte `json:"sig"`
+	} `json:"signatures"`
+}
+
+type inTotoStatement struct {
+	PredicateType string `json:"predicateType"`
+	Subject       []struct {
+		Name   string            `json:"name"`
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+}
+
+// sigstoreTrustedRoot is the subset of trusted_root.json naming the certificate
+// authorities and transparency logs.
+type sigstoreTrustedRoot struct {
+	CertificateAuthorities []struct {
+		CertChain struct {
+			Certificates []sigstoreRawBytes `json:"certificates"`
+		} `json:"certChain"`
+	} `json:"certificateAuthorities"`
+	Tlogs []struct {
+		PublicKey sigstoreRawBytes `json:"publicKey"`
+	} `json:"tlogs"`
+}
+
+// sigstoreVerifier checks sources against sigstore bundles and SLSA provenance offline.
+// It verifies the signed entry timestamp of the transparency log, the certificate
+// chain up to the trusted root at that time, the signing identity and the
+// signature, transparency log inclusion proofs are not checked.
+type sigstoreVerifier struct {
+	roots         *x509.CertPool
+	intermediates *x509.CertPool
+	// tlogs are the transparency log keys by their hex log ID
+	tlogs    map[string]crypto.PublicKey
+	identity *regexp.Regexp
+	issuer   string
+}
+
+func newSigstoreVerifier(trustedRootPath, identity, issuer string) (sigstoreVerifier, error) {
+	if identity == "" || issuer == "" {
+		return sigstoreVerifier{}, fmt.Errorf("a sigstore identity and issuer are required")
+	}
+	content, err := os.ReadFile(trustedRootPath)
+	if err != nil {
+		return sigstoreVerifier{}, fmt.Errorf("failed to read trusted root: %w", err)
+	}
+	var trustedRoot sigstoreTrustedRoot
+	if err := json.Unmarshal(content, &trustedRoot); err != nil {
+		return sigstoreVerifier{}, fmt.Errorf("Could not unmarshal the trusted root: %w", err)
+	}
+
+	verifier := sigstoreVerifier{
+		roots:         x509.NewCertPool(),
+		intermediates: x509.NewCertPool(),
+		tlogs:         map[string]crypto.PublicKey{},
+		issuer:        issuer,
+	}
+	for _, authority := range trustedRoot.CertificateAuthorities {
+		chain := authority.CertChain.Certificates
+		for i, raw := range chain {
+			cert, err := x509.ParseCertificate(raw.RawBytes)
+			if err != nil {
+				return sigstoreVerifier{}, fmt.Errorf("invalid certificate in trusted root: %w", err)
+			}
+			// the chain is ordered from the issuing certificate up to the root
+			if i == len(chain)-1 {
+				verifier.roots.AddCert(cert)
+			} else {
+				verifier.intermediates.AddCert(cert)
+			}
+		}
+	}
+	for _, tlog := range trustedRoot.Tlogs {
+		key, err := x509.ParsePKIXPublicKey(tlog.PublicKey.RawBytes)
+		if err != nil {
+			return sigstoreVerifier{}, fmt.Errorf("invalid transparency log key in trusted root: %w", err)
+		}
+		// the log ID is the SHA-256 of the DER encoded key
+		logID := sha256.Sum256(tlog.PublicKey.RawBytes)
+		verifier.tlogs[hex.EncodeToString(logID[:])] = key
+	}
+	if verifier.identity, err = regexp.Compile(identity); err != nil {
+		return sigstoreVerifier{}, fmt.Errorf("invalid sigstore identity %q: %w", identity, err)
+	}
+	return verifier, nil
+}
+
+func parseSigstoreBundle(content []byte) (sigstoreBundle, error) {
+	var bundle sigstoreBundle
+	if err := json.Unmarshal(content, &bundle); err != nil {
+		return sigstoreBundle{}, fmt.Errorf("Could not unmarshal the sigstore bundle: %w", err)
+	}
+	return bundle, nil
+}
+
+// verifyCertificate checks the bundle certificate chains to the trusted root at
+// signing time and was issued to the expected identity, and returns it.
+func (verifier sigstoreVerifier) verifyCertificate(bundle sigstoreBundle) (*x509.Certificate, error) {
+	var raw []byte
+	material := bundle.VerificationMaterial
+	if material.Certificate != nil {
+		raw = material.Certificate.RawBytes
+	} else if material.X509CertificateChain != nil && len(material.X509CertificateChain.Certificates) != 0 {
+		raw = material.X509CertificateChain.Certificates[0].RawBytes
+	} else {
+		return nil, fmt.Errorf("bundle has no signing certificate")
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing certificate: %w", err)
+	}
+
+	// Fulcio certificates are short lived, they have to be valid when the signature was logged
+	signingTime, err := verifier.signingTime(material.TlogEntries, cert)
+	if err != nil {
+		return nil, err
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:         verifier.roots,
+		Intermediates: verifier.intermediates,
+		CurrentTime:   signingTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("signing certificate is not trusted: %w", err)
+	}
+
+	identities := cert.EmailAddresses
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	if !slices.ContainsFunc(identities, verifier.identity.MatchString) {
+		return nil, fmt.Errorf("signing identity %v does not match %q", identities, verifier.identity)
+	}
+	if issuer := certificateIssuer(cert); issuer != verifier.issuer {
+		return nil, fmt.Errorf("signing certificate issuer %q is not %q", issuer, verifier.issuer)
+	}
+	return cert, nil
+}
+
+// signingTime returns when a trusted transparency log integrated an entry for
+// cert, only signed entry timestamps are trusted.
+func (verifier sigstoreVerifier) signingTime(entries []sigstoreTlogEntry, cert *x509.Certificate) (time.Time, error) {
+	if len(entries) == 0 {
+		return time.Time{}, fmt.Errorf("bundle has no transparency log entry")
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	var err error
+	for _, entry := range entries {
+		if err = verifier.verifyTlogEntry(entry); err != nil {
+			continue
+		}
+		// the entry body holds the base64 encoded PEM of the certificate that signed
+		if !bytes.Contains(entry.CanonicalizedBody, []byte(base64.StdEncoding.EncodeToString(certPEM))) {
+			err = fmt.Errorf("transparency log entry %d is not for the signing certificate", entry.LogIndex)
+			continue
+		}
+		return time.Unix(entry.IntegratedTime, 0), nil
+	}
+	return time.Time{}, err
+}
+
+func (verifier sigstoreVerifier) verifyTlogEntry(entry sigstoreTlogEntry) error {
+	logID := hex.EncodeToString(entry.LogID.KeyID)
+	key, ok := verifier.tlogs[logID]
+	if !ok {
+		return fmt.Errorf("transparency log %s is not in the trusted root", logID)
+	}
+	if entry.InclusionPromise == nil {
+		return fmt.Errorf("transparency log entry %d has no signed entry timestamp", entry.LogIndex)
+	}
+	payload, err := json.Marshal(signedEntryTimestamp{
+		Body:           base64.StdEncoding.EncodeToString(entry.CanonicalizedBody),
+		IntegratedTime: entry.IntegratedTime,
+		LogID:          logID,
+		LogIndex:       entry.LogIndex,
+	})
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(key, payload, entry.InclusionPromise.SignedEntryTimestamp); err != nil {
+		return fmt.Errorf("signed entry timestamp of transparency log entry %d: %w", entry.LogIndex, err)
+	}
+	return nil
+}
+
+func certificateIssuer(cert *x509.Certificate) string {
+	for _, extension := range cert.Extensions {
+		if extension.Id.Equal(oidcIssuerV2) {
+			var issuer string
+			if _, err := asn1.Unmarshal(extension.Value, &issuer); err == nil {
+				return issuer
+			}
+		}
+	}
+	for _, extension := range cert.Extensions {
+		if extension.Id.Equal(oidcIssuerV1) {
+			return string(extension.Value)
+		}
+	}
+	return ""
+}
+
+func verifySignature(publicKey crypto.PublicKey, message, signature []byte) error {
+	digest := sha256.Sum256(message)
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return fmt.Errorf("invalid ECDSA signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, message, signature) {
+			return fmt.Errorf("invalid Ed25519 signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported public key type %T", publicKey)
+}
+
+// verifyBundle verifies a message signature bundle (cosign sign-blob) for data.
+func (verifier sigstoreVerifier) verifyBundle(bundle sigstoreBundle, data []byte) error {
+	if bundle.MessageSignature == nil {
+		return fmt.Errorf("bundle has no message signature")
+	}
+	cert, err := verifier.verifyCertificate(bundle)
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(data)
+	signature := bundle.MessageSignature
+	if len(signature.MessageDigest.Digest) != 0 && !bytes.Equal(signature.MessageDigest.Digest, digest[:]) {
+		return fmt.Errorf("bundle digest does not match the source")
+	}
+	return verifySignature(cert.PublicKey, data, signature.Signature)
+}
+
+// dssePAE is the DSSE pre-authentication encoding the envelope signature is made over.
+func dssePAE(payloadType string, payload []byte) []byte {
+	return fmt.Appendf(nil, "DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload)
+}
+
+// verifyProvenance verifies a DSSE bundle holding SLSA provenance and returns its statement.
+func (verifier sigstoreVerifier) verifyProvenance(bundle sigstoreBundle) (inTotoStatement, error) {
+	envelope := bundle.DsseEnvelope
+	if envelope == nil || len(envelope.Signatures) == 0 {
+		return inTotoStatement{}, fmt.Errorf("provenance has no signed DSSE envelope")
+	}
+	cert, err := verifier.verifyCertificate(bundle)
+	if err != nil {
+		return inTotoStatement{}, err
+	}
+	if err := verifySignature(cert.PublicKey, dssePAE(envelope.PayloadType, envelope.Payload), envelope.Signatures[0].Sig); err != nil {
+		return inTotoStatement{}, fmt.Errorf("provenance signature: %w", err)
+	}
+
+	var statement inTotoStatement
+	if err := json.Unmarshal(envelope.Payload, &statement); err != nil {
+		return inTotoStatement{}, fmt.Errorf("Could not unmarshal the provenance statement: %w", err)
+	}
+	if !strings.HasPrefix(statement.PredicateType, "https://slsa.dev/provenance/") {
+		return inTotoStatement{}, fmt.Errorf("unexpected provenance predicate type %q", statement.PredicateType)
+	}
+	return statement, nil
+}
+
+func (statement inTotoStatement) covers(data []byte) bool {
+	digest := sha256.Sum256(data)
+	checksum := hex.EncodeToString(digest[:])
+	for _, subject := range statement.Subject {
+		if strings.EqualFold(subject.Digest["sha256"], checksum) {
+			return true
+		}
+	}
+	return false
+}
+
+func readLocation(get func(string) ([]byte, error), location string) ([]byte, error) {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		return get(location)
+	}
+	return os.ReadFile(location)
+}
+
+// verifySourceAttestations downloads every source matching verifiedSources and verifies it
+// against its sigstore bundle and the SLSA provenance before any checksum is calculated.
+func (pkgbuild PkgBuild) verifySourceAttestations(get func(string) ([]byte, error)) error {
+	verifier, err := newSigstoreVerifier(pkgbuild.sigstoreTrustedRoot, pkgbuild.sigstoreIdentity, pkgbuild.sigstoreIssuer)
+	if err != nil {
+		return err
+	}
+
+	var provenance *inTotoStatement
+	if pkgbuild.slsaProvenance != "" {
+		content, err := readLocation(get, pkgbuild.slsaProvenance)
+		if err != nil {
+			return fmt.Errorf("failed to read provenance %v: %w", pkgbuild.slsaProvenance, err)
+		}
+		bundle, err := parseSigstoreBundle(content)
+		if err != nil {
+			return err
+		}
+		statement, err := verifier.verifyProvenance(bundle)
+		if err != nil {
+			return err
+		}
+		provenance = &statement
+	}
+
+	for _, source := range slices.Concat(pkgbuild.Source, pkgbuild.Source_x86_64, pkgbuild.Source_aarch64) {
+		filename := sourceFilename(source)
+		if signatureExtension(filename) != "" {
+			continue
+		}
+		if matched, err := path.Match(pkgbuild.verifiedSources, filename); err != nil {
+			return fmt.Errorf("invalid verified sources pattern %q: %w", pkgbuild.verifiedSources, err)
+		} else if !matched {
+			continue
+		}
+
+		_, url := parser.SplitSource(source)
+		data, err := get(url)
+		if err != nil {
+			return fmt.Errorf("failed to download source %v: %w", url, err)
+		}
+
+		if pkgbuild.sigstoreBundleSuffix != "" {
+			content, err := get(url + pkgbuild.sigstoreBundleSuffix)
+			if err != nil {
+				return fmt.Errorf("failed to download sigstore bundle for %s: %w", filename, err)
+			}
+			bundle, err := parseSigstoreBundle(content)
+			if err != nil {
+				return err
+			}
+			if err := verifier.verifyBundle(bundle, data); err != nil {
+				return fmt.Errorf("sigstore bundle does not verify %s: %w", filename, err)
+			}
+			slog.Info("Verified sigstore bundle", "source", filename)
+		}
+		if provenance != nil {
+			if !provenance.covers(data) {
+				return fmt.Errorf("%s is not a subject of the SLSA provenance", filename)
+			}
+			slog.Info("Verified SLSA provenance", "source", filename)
+		}
+	}
+	return nil
+}
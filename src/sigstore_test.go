@@ -0,0 +1,427 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSigstore struct {
+	key         *ecdsa.PrivateKey
+	leaf        []byte
+	rekorKey    *ecdsa.PrivateKey
+	trustedRoot string
+}
+
+func newTestSigstore(t *testing.T, identity, issuer string) testSigstore {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test fulcio"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	assert.NoError(t, err)
+	ca, _ := x509.ParseCertificate(caDER)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	identityURI, _ := url.Parse(identity)
+	issuerValue, _ := asn1.Marshal(issuer)
+	leafTemplate := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       time.Now().Add(-time.Minute),
+		NotAfter:        time.Now().Add(10 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		URIs:            []*url.URL{identityURI},
+		ExtraExtensions: []pkix.Extension{{Id: oidcIssuerV2, Value: issuerValue}},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, ca, &leafKey.PublicKey, caKey)
+	assert.NoError(t, err)
+
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	rekorDER, err := x509.MarshalPKIXPublicKey(&rekorKey.PublicKey)
+	assert.NoError(t, err)
+
+	trustedRoot, _ := json.Marshal(map[string]any{
+		"certificateAuthorities": []any{map[string]any{
+			"certChain": map[string]any{"certificates": []any{map[string]any{"rawBytes": caDER}}},
+		}},
+		"tlogs": []any{map[string]any{"publicKey": map[string]any{"rawBytes": rekorDER}}},
+	})
+	trustedRootPath := filepath.Join(t.TempDir(), "trusted_root.json")
+	assert.NoError(t, os.WriteFile(trustedRootPath, trustedRoot, 0644))
+
+	return testSigstore{key: leafKey, leaf: leafDER, rekorKey: rekorKey, trustedRoot: trustedRootPath}
+}
+
+// tlogEntry is a Rekor entry for body integrated at integratedTime, with its signed entry timestamp.
+func (s testSigstore) tlogEntry(t *testing.T, body []byte, integratedTime time.Time) map[string]any {
+	rekorDER, _ := x509.MarshalPKIXPublicKey(&s.rekorKey.PublicKey)
+	logID := sha256.Sum256(rekorDER)
+	payload, _ := json.Marshal(signedEntryTimestamp{
+		Body:           base64.StdEncoding.EncodeToString(body),
+		IntegratedTime: integratedTime.Unix(),
+		LogID:          hex.EncodeToString(logID[:]),
+		LogIndex:       7,
+	})
+	digest := sha256.Sum256(payload)
+	set, err := ecdsa.SignASN1(rand.Reader, s.rekorKey, digest[:])
+	assert.NoError(t, err)
+	return map[string]any{
+		"logIndex":          "7",
+		"logId":             map[string]any{"keyId": logID[:]},
+		"integratedTime":    fmt.Sprint(integratedTime.Unix()),
+		"inclusionPromise":  map[string]any{"signedEntryTimestamp": set},
+		"canonicalizedBody": body,
+	}
+}
+
+// entryBody is a hashedrekord body naming the leaf certificate.
+func (s testSigstore) entryBody() []byte {
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.leaf})
+	body, _ := json.Marshal(map[string]any{
+		"kind": "hashedrekord",
+		"spec": map[string]any{"signature": map[string]any{"publicKey": map[string]any{"content": certPEM}}},
+	})
+	return body
+}
+
+// signBlob returns a cosign sign-blob bundle for data, tlogEntries replace the
+// entry logging the signature now.
+func (s testSigstore) signBlob(t *testing.T, data []byte, tlogEntries ...any) []byte {
+	digest := sha256.Sum256(data)
+	signature, err := ecdsa.SignASN1(rand.Reader, s.key, digest[:])
+	assert.NoError(t, err)
+	if tlogEntries == nil {
+		tlogEntries = []any{s.tlogEntry(t, s.entryBody(), time.Now())}
+	}
+	bundle, _ := json.Marshal(map[string]any{
+		"mediaType": "application/vnd.dev.sigstore.bundle.v0.3+json",
+		"verificationMaterial": map[string]any{
+			"certificate": map[string]any{"rawBytes": s.leaf},
+			"tlogEntries": tlogEntries,
+		},
+		"messageSignature": map[string]any{
+			"messageDigest": map[string]any{"algorithm": "SHA2_256", "digest": digest[:]},
+			"signature":     signature,
+		},
+	})
+	return bundle
+}
+
+func (s testSigstore) signProvenance(t *testing.T, predicateType string, subjects ...[]byte) []byte {
+	var subject []any
+	for i, data := range subjects {
+		digest := sha256.Sum256(data)
+		subject = append(subject, map[string]any{"name": fmt.Sprint(i), "digest": map[string]string{"sha256": hex.EncodeToString(digest[:])}})
+	}
+	payload, _ := json.Marshal(map[string]any{"_type": "https://in-toto.io/Statement/v1", "predicateType": predicateType, "subject": subject})
+	payloadType := "application/vnd.in-toto+json"
+	digest := sha256.Sum256(dssePAE(payloadType, payload))
+	signature, err := ecdsa.SignASN1(rand.Reader, s.key, digest[:])
+	assert.NoError(t, err)
+	bundle, _ := json.Marshal(map[string]any{
+		"verificationMaterial": map[string]any{
+			"x509CertificateChain": map[string]any{"certificates": []any{map[string]any{"rawBytes": s.leaf}}},
+			"tlogEntries":          []any{s.tlogEntry(t, s.entryBody(), time.Now())},
+		},
+		"dsseEnvelope": map[string]any{"payload": payload, "payloadType": payloadType, "signatures": []any{map[string]any{"sig": signature}}},
+	})
+	return bundle
+}
+
+func TestVerifySourceAttestations(t *testing.T) {
+	identity := "https://github.com/fuad-daoud/pkg/.github/workflows/release.yml@refs/tags/v1.0.0"
+	issuer := "https://token.actions.githubusercontent.com"
+	trusted := newTestSigstore(t, identity, issuer)
+	untrusted := newTestSigstore(t, identity, issuer)
+
+	binary := []byte("binary content")
+	license := []byte("license")
+	forged := trusted.tlogEntry(t, trusted.entryBody(), time.Now())
+	forged["integratedTime"] = fmt.Sprint(time.Now().Add(-time.Hour).Unix())
+	untrustedLog := untrusted.tlogEntry(t, trusted.entryBody(), time.Now())
+	noPromise := trusted.tlogEntry(t, trusted.entryBody(), time.Now())
+	delete(noPromise, "inclusionPromise")
+	files := map[string][]byte{
+		"https://example.com/pkg":                         binary,
+		"https://example.com/pkg.sigstore.json":           trusted.signBlob(t, binary),
+		"https://example.com/pkg.sig":                     []byte("not downloaded"),
+		"https://example.com/tampered":                    []byte("tampered"),
+		"https://example.com/tampered.sigstore.json":      trusted.signBlob(t, binary),
+		"https://example.com/untrusted":                   binary,
+		"https://example.com/untrusted.sigstore.json":     untrusted.signBlob(t, binary, trusted.tlogEntry(t, untrusted.entryBody(), time.Now())),
+		"https://example.com/LICENSE":                     license,
+		"https://example.com/unlogged.sigstore.json":      trusted.signBlob(t, binary, []any{}...),
+		"https://example.com/forged.sigstore.json":        trusted.signBlob(t, binary, forged),
+		"https://example.com/untrusted-log.sigstore.json": trusted.signBlob(t, binary, untrustedLog),
+		"https://example.com/no-promise.sigstore.json":    trusted.signBlob(t, binary, noPromise),
+		"https://example.com/other-entry.sigstore.json":   trusted.signBlob(t, binary, trusted.tlogEntry(t, untrusted.entryBody(), time.Now())),
+		"https://example.com/expired.sigstore.json":       trusted.signBlob(t, binary, trusted.tlogEntry(t, trusted.entryBody(), time.Now().Add(time.Hour))),
+		"https://example.com/provenance.intoto.jsonl":     trusted.signProvenance(t, "https://slsa.dev/provenance/v1", binary),
+		"https://example.com/attestation.intoto.jsonl":    trusted.signProvenance(t, "https://spdx.dev/Document", binary),
+		"https://example.com/invalid.json":                []byte("{"),
+	}
+	for _, name := range []string{"unlogged", "forged", "untrusted-log", "no-promise", "other-entry", "expired"} {
+		files["https://example.com/"+name] = binary
+	}
+	get := func(url string) ([]byte, error) {
+		if body, ok := files[url]; ok {
+			return body, nil
+		}
+		return nil, fmt.Errorf("404 Not Found")
+	}
+
+	tests := []struct {
+		name        string
+		pkg         PkgBuild
+		errContains string
+	}{
+		{
+			name: "bundle and provenance",
+			pkg: PkgBuild{
+				Source_x86_64:        []string{"pkg-1.0.0-x86_64::https://example.com/pkg", "pkg-1.0.0-x86_64.sig::https://example.com/pkg.sig", "LICENSE::https://example.com/LICENSE"},
+				verifiedSources:      "pkg-*",
+				sigstoreBundleSuffix: ".sigstore.json",
+				sigstoreIdentity:     `^https://github\.com/fuad-daoud/pkg/`,
+				sigstoreIssuer:       issuer,
+				slsaProvenance:       "https://example.com/provenance.intoto.jsonl",
+			},
+		},
+		{
+			name: "source not in provenance",
+			pkg: PkgBuild{
+				Source:          []string{"https://example.com/LICENSE"},
+				verifiedSources: "*",
+				slsaProvenance:  "https://example.com/provenance.intoto.jsonl",
+			},
+			errContains: "LICENSE is not a subject of the SLSA provenance",
+		},
+		{
+			name: "not a provenance predicate",
+			pkg: PkgBuild{
+				verifiedSources: "*",
+				slsaProvenance:  "https://example.com/attestation.intoto.jsonl",
+			},
+			errContains: "unexpected provenance predicate type",
+		},
+		{
+			name: "provenance without envelope",
+			pkg: PkgBuild{
+				verifiedSources: "*",
+				slsaProvenance:  "https://example.com/pkg.sigstore.json",
+			},
+			errContains: "provenance has no signed DSSE envelope",
+		},
+		{
+			name: "missing provenance",
+			pkg: PkgBuild{
+				verifiedSources: "*",
+				slsaProvenance:  "testdata/missing.intoto.jsonl",
+			},
+			errContains: "failed to read provenance",
+		},
+		{
+			name: "invalid provenance",
+			pkg: PkgBuild{
+				verifiedSources: "*",
+				slsaProvenance:  "https://example.com/invalid.json",
+			},
+			errContains: "unmarshal the sigstore bundle",
+		},
+		{
+			name: "tampered source",
+			pkg: PkgBuild{
+				Source:               []string{"https://example.com/tampered"},
+				verifiedSources:      "*",
+				sigstoreBundleSuffix: ".sigstore.json",
+			},
+			errContains: "bundle digest does not match the source",
+		},
+		{
+			name: "untrusted certificate",
+			pkg: PkgBuild{
+				Source:               []string{"https://example.com/untrusted"},
+				verifiedSources:      "*",
+				sigstoreBundleSuffix: ".sigstore.json",
+			},
+			errContains: "signing certificate is not trusted",
+		},
+		{
+			name: "no transparency log entry",
+			pkg: PkgBuild{
+				Source:               []string{"https://example.com/unlogged"},
+				verifiedSources:      "*",
+				sigstoreBundleSuffix: ".sigstore.json",
+			},
+			errContains: "bundle has no transparency log entry",
+		},
+		{
+			name: "forged integrated time",
+			pkg: PkgBuild{
+				Source:               []string{"https://example.com/forged"},
+				verifiedSources:      "*",
+				sigstoreBundleSuffix: ".sigstore.json",
+			},
+			errContains: "signed entry timestamp of transparency log entry 7",
+		},
+		{
+			name: "untrusted transparency log",
+			pkg: PkgBuild{
+				Source:               []string{"https://example.com/untrusted-log"},
+				verifiedSources:      "*",
+				sigstoreBundleSuffix: ".sigstore.json",
+			},
+			errContains: "is not in the trusted root",
+		},
+		{
+			name: "no signed entry timestamp",
+			pkg: PkgBuild{
+				Source:               []string{"https://example.com/no-promise"},
+				verifiedSources:      "*",
+				sigstoreBundleSuffix: ".sigstore.json",
+			},
+			errContains: "transparency log entry 7 has no signed entry timestamp",
+		},
+		{
+			name: "entry for another certificate",
+			pkg: PkgBuild{
+				Source:               []string{"https://example.com/other-entry"},
+				verifiedSources:      "*",
+				sigstoreBundleSuffix: ".sigstore.json",
+			},
+			errContains: "transparency log entry 7 is not for the signing certificate",
+		},
+		{
+			name: "certificate expired when logged",
+			pkg: PkgBuild{
+				Source:               []string{"https://example.com/expired"},
+				verifiedSources:      "*",
+				sigstoreBundleSuffix: ".sigstore.json",
+			},
+			errContains: "signing certificate is not trusted",
+		},
+		{
+			name: "other identity",
+			pkg: PkgBuild{
+				Source:               []string{"https://example.com/pkg"},
+				verifiedSources:      "*",
+				sigstoreBundleSuffix: ".sigstore.json",
+				sigstoreIdentity:     `^https://github\.com/someone-else/`,
+			},
+			errContains: "does not match",
+		},
+		{
+			name: "other issuer",
+			pkg: PkgBuild{
+				Source:               []string{"https://example.com/pkg"},
+				verifiedSources:      "*",
+				sigstoreBundleSuffix: ".sigstore.json",
+				sigstoreIssuer:       "https://accounts.google.com",
+			},
+			errContains: `is not "https://accounts.google.com"`,
+		},
+		{
+			name: "missing bundle",
+			pkg: PkgBuild{
+				Source:               []string{"https://example.com/LICENSE"},
+				verifiedSources:      "*",
+				sigstoreBundleSuffix: ".sigstore.json",
+			},
+			errContains: "failed to download sigstore bundle for LICENSE",
+		},
+		{
+			name: "missing source",
+			pkg: PkgBuild{
+				Source:               []string{"https://example.com/missing"},
+				verifiedSources:      "*",
+				sigstoreBundleSuffix: ".sigstore.json",
+			},
+			errContains: "failed to download source",
+		},
+		{
+			name: "invalid pattern",
+			pkg: PkgBuild{
+				Source:          []string{"https://example.com/pkg"},
+				verifiedSources: "[",
+			},
+			errContains: "invalid verified sources pattern",
+		},
+		{
+			name: "invalid identity",
+			pkg: PkgBuild{
+				sigstoreIdentity: "(",
+			},
+			errContains: "invalid sigstore identity",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.pkg.sigstoreTrustedRoot = trusted.trustedRoot
+			if tt.pkg.sigstoreIdentity == "" {
+				tt.pkg.sigstoreIdentity = "^" + regexp.QuoteMeta(identity) + "$"
+			}
+			if tt.pkg.sigstoreIssuer == "" {
+				tt.pkg.sigstoreIssuer = issuer
+			}
+			err := tt.pkg.verifySourceAttestations(get)
+			if tt.errContains != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errContains)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Run("missing identity", func(t *testing.T) {
+		pkg := PkgBuild{sigstoreTrustedRoot: trusted.trustedRoot, sigstoreIssuer: issuer}
+		err := pkg.verifySourceAttestations(get)
+		assert.EqualError(t, err, "a sigstore identity and issuer are required")
+	})
+
+	t.Run("missing trusted root", func(t *testing.T) {
+		pkg := PkgBuild{sigstoreTrustedRoot: "testdata/missing.json", sigstoreIdentity: ".", sigstoreIssuer: issuer}
+		err := pkg.verifySourceAttestations(get)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read trusted root")
+	})
+
+	t.Run("invalid trusted root", func(t *testing.T) {
+		pkg := PkgBuild{sigstoreTrustedRoot: "testdata/PKGBUILD", sigstoreIdentity: ".", sigstoreIssuer: issuer}
+		err := pkg.verifySourceAttestations(get)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unmarshal the trusted root")
+	})
+}
+
+func TestDssePAE(t *testing.T) {
+	assert.Equal(t, "DSSEv1 29 http://example.com/HelloWorld 11 hello world", string(dssePAE("http://example.com/HelloWorld", []byte("hello world"))))
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// spdxLists holds the SPDX license and exception identifiers, one per line.
+//
+//go:embed spdx/*.txt
+var spdxLists embed.FS
+
+var (
+	spdxLicences   = sync.OnceValue(func() map[string]string { return loadSPDXList("spdx/licenses.txt") })
+	spdxExceptions = sync.OnceValue(func() map[string]string { return loadSPDXList("spdx/exceptions.txt") })
+)
+
+// licenceAliases maps deprecated SPDX identifiers and the license names Arch
+// used before SPDX to their SPDX identifiers.
+var licenceAliases = map[string]string{
+	"AGPL-1.0":     "AGPL-1.0-only",
+	"AGPL-3.0":     "AGPL-3.0-only",
+	"AGPL3":        "AGPL-3.0-only",
+	"Apache":       "Apache-2.0",
+	"Apache2":      "Apache-2.0",
+	"Boost":        "BSL-1.0",
+	"CDDL":         "CDDL-1.0",
+	"EPL":          "EPL-1.0",
+	"FDL1.2":       "GFDL-1.2-only",
+	"FDL1.3":       "GFDL-1.3-only",
+	"GFDL-1.1":     "GFDL-1.1-only",
+	"GFDL-1.2":     "GFDL-1.2-only",
+	"GFDL-1.3":     "GFDL-1.3-only",
+	"GPL-1.0":      "GPL-1.0-only",
+	"GPL-2.0":      "GPL-2.0-only",
+	"GPL-3.0":      "GPL-3.0-only",
+	"GPL2":         "GPL-2.0-only",
+	"GPL3":         "GPL-3.0-only",
+	"LGPL-2.0":     "LGPL-2.0-only",
+	"LGPL-2.1":     "LGPL-2.1-only",
+	"LGPL-3.0":     "LGPL-3.0-only",
+	"LGPL2.1":      "LGPL-2.1-only",
+	"LGPL3":        "LGPL-3.0-only",
+	"MPL2":         "MPL-2.0",
+	"PerlArtistic": "Artistic-1.0-Perl",
+	"PHP":          "PHP-3.01",
+	"PSF":          "PSF-2.0",
+	"PYTHON":       "PSF-2.0",
+	"RUBY":         "Ruby",
+	"ZPL":          "ZPL-2.1",
+}
+
+var licenceRefPattern = regexp.MustCompile(`^(DocumentRef-[A-Za-z0-9.-]+:)?LicenseRef-[A-Za-z0-9.-]+$`)
+
+// packageFunctionPattern matches the package() functions of a PKGBUILD, split
+// packages have one package_<name>() per package.
+var packageFunctionPattern = regexp.MustCompile(`(?ms)^package(_\S+)?\(\)\s*\{\n(.*?)^\}`)
+
+// loadSPDXList maps the lowercase form of every identifier in name to the
+// identifier, SPDX identifiers match case-insensitively.
+func loadSPDXList(name string) map[string]string {
+	content, _ := spdxLists.ReadFile(name)
+	ids := map[string]string{}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			ids[strings.ToLower(line)] = line
+		}
+	}
+	return ids
+}
+
+func lookupLicenceAlias(id string) (string, bool) {
+	for alias, spdx := range licenceAliases {
+		if strings.EqualFold(alias, id) {
+			return spdx, true
+		}
+	}
+	return "", false
+}
+
+// normalizeLicence parses an SPDX license expression like "MIT OR Apache-2.0"
+// and returns it with every identifier spelled as on the SPDX license list. An
+// alias is replaced by its SPDX identifier with a warning.
+func normalizeLicence(expression string) (string, []string, error) {
+	parser := licenceParser{tokens: tokenizeLicence(expression)}
+	if len(parser.tokens) == 0 {
+		return "", nil, fmt.Errorf("the license expression is empty")
+	}
+	if err := parser.or(); err != nil {
+		return "", nil, err
+	}
+	if parser.pos < len(parser.tokens) {
+		return "", nil, fmt.Errorf("unexpected %q", parser.tokens[parser.pos])
+	}
+	return strings.NewReplacer("( ", "(", " )", ")").Replace(strings.Join(parser.out, " ")), parser.warnings, nil
+}
+
+func tokenizeLicence(expression string) []string {
+	return strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expression))
+}
+
+// licenceParser is a recursive descent parser of SPDX license expressions, OR
+// binds weaker than AND, which binds weaker than WITH.
+type licenceParser struct {
+	tokens   []string
+	pos      int
+	out      []string
+	warnings []string
+}
+
+func (parser *licenceParser) peek(operator string) bool {
+	return parser.pos < len(parser.tokens) && strings.EqualFold(parser.tokens[parser.pos], operator)
+}
+
+func (parser *licenceParser) next() string {
+	if parser.pos == len(parser.tokens) {
+		return ""
+	}
+	parser.pos++
+	return parser.tokens[parser.pos-1]
+}
+
+func (parser *licenceParser) or() error {
+	if err := parser.and(); err != nil {
+		return err
+	}
+	for parser.peek("OR") {
+		parser.next()
+		parser.out = append(parser.out, "OR")
+		if err := parser.and(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (parser *licenceParser) and() error {
+	if err := parser.with(); err != nil {
+		return err
+	}
+	for parser.peek("AND") {
+		parser.next()
+		parser.out = append(parser.out, "AND")
+		if err := parser.with(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (parser *licenceParser) with() error {
+	if err := parser.simple(); err != nil {
+		return err
+	}
+	if !parser.peek("WITH") {
+		return nil
+	}
+	parser.next()
+	token := parser.next()
+	exception, ok := spdxExceptions()[strings.ToLower(token)]
+	if !ok {
+		return fmt.Errorf("unknown SPDX license exception %q", token)
+	}
+	parser.out = append(parser.out, "WITH", exception)
+	return nil
+}
+
+func (parser *licenceParser) simple() error {
+	token := parser.next()
+	switch {
+	case token == "":
+		return fmt.Errorf("the license expression ends early")
+	case token == "(":
+		parser.out = append(parser.out, "(")
+		if err := parser.or(); err != nil {
+			return err
+		}
+		if parser.next() != ")" {
+			return fmt.Errorf("missing )")
+		}
+		parser.out = append(parser.out, ")")
+		return nil
+	case token == ")" || strings.EqualFold(token, "OR") || strings.EqualFold(token, "AND") || strings.EqualFold(token, "WITH"):
+		return fmt.Errorf("unexpected %q", token)
+	}
+	id, err := parser.licence(token)
+	if err != nil {
+		return err
+	}
+	parser.out = append(parser.out, id)
+	return nil
+}
+
+// licence returns the SPDX identifier of token, which can end with the "+"
+// operator, a deprecated GNU "+" becomes the -or-later identifier.
+func (parser *licenceParser) licence(token string) (string, error) {
+	if licenceRefPattern.MatchString(token) {
+		return token, nil
+	}
+	if strings.HasPrefix(strings.ToLower(token), "custom") {
+		return "", fmt.Errorf("%q is not an SPDX identifier, name a custom license LicenseRef-<name> and install its license file in package()", token)
+	}
+	base, plus := strings.CutSuffix(token, "+")
+	id, ok := spdxLicences()[strings.ToLower(base)]
+	if !ok {
+		if id, ok = lookupLicenceAlias(base); !ok {
+			return "", fmt.Errorf("unknown SPDX license identifier %q", base)
+		}
+	}
+	if plus {
+		if only, ok := strings.CutSuffix(id, "-only"); ok {
+			id = only + "-or-later"
+		} else {
+			id += "+"
+		}
+	}
+	if id != token {
+		warning := fmt.Sprintf("Licence %s is not an SPDX identifier, using %s", token, id)
+		parser.warnings = append(parser.warnings, warning)
+	}
+	return id, nil
+}
+
+// normalizeLicences spells every Licence as its SPDX expression, see normalizeLicence.
+func (pkgbuild *PkgBuild) normalizeLicences() error {
+	licences := make([]string, len(pkgbuild.Licence))
+	for i, licence := range pkgbuild.Licence {
+		normalized, warnings, err := normalizeLicence(licence)
+		if err != nil {
+			return invalid("Licence", "Invalid Licence %q: %v", licence, err)
+		}
+		for _, warning := range warnings {
+			slog.Warn(warning)
+		}
+		pkgbuild.warnings = append(pkgbuild.warnings, warnings...)
+		licences[i] = normalized
+	}
+	pkgbuild.Licence = licences
+	return nil
+}
+
+// checkCustomLicences warns about every LicenseRef- license whose license file
+// PKGBUILD does not install, Arch requires custom licenses to be installed.
+func (pkgbuild *PkgBuild) checkCustomLicences(PKGBUILD string) {
+	for _, licence := range pkgbuild.Licence {
+		for _, token := range tokenizeLicence(licence) {
+			if !licenceRefPattern.MatchString(token) || licenceInstalled(PKGBUILD, token) {
+				continue
+			}
+			warning := fmt.Sprintf("Licence %s is a custom license, install a license file named after it to /usr/share/licenses/$pkgname in package()", token)
+			slog.Warn(warning)
+			pkgbuild.warnings = append(pkgbuild.warnings, warning)
+		}
+	}
+}
+
+// licenceInstalled reports whether a package() function of PKGBUILD installs a
+// file to /usr/share/licenses on a line naming licenceRef, like
+// LICENSE-Proprietary for LicenseRef-Proprietary.
+func licenceInstalled(PKGBUILD, licenceRef string) bool {
+	_, name, _ := strings.Cut(licenceRef, "LicenseRef-")
+	name = strings.ToLower(name)
+	// $pkgname is in every license path, it must not count as the name
+	withoutPkgname := strings.NewReplacer("${pkgname}", "", "$pkgname", "")
+	for _, function := range packageFunctionPattern.FindAllStringSubmatch(PKGBUILD, -1) {
+		for _, line := range strings.Split(function[2], "\n") {
+			line = strings.ToLower(withoutPkgname.Replace(line))
+			if strings.Contains(line, "/usr/share/licenses/") && strings.Contains(line, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
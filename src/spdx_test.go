@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeLicence(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+		warnings   []string
+	}{
+		{expression: "MIT", want: "MIT"},
+		{expression: "MIT OR Apache-2.0", want: "MIT OR Apache-2.0"},
+		{expression: "mit or apache-2.0", want: "MIT OR Apache-2.0", warnings: []string{
+			"Licence mit is not an SPDX identifier, using MIT",
+			"Licence apache-2.0 is not an SPDX identifier, using Apache-2.0",
+		}},
+		{expression: "GPL-2.0-or-later WITH Classpath-exception-2.0", want: "GPL-2.0-or-later WITH Classpath-exception-2.0"},
+		{expression: "(MIT OR BSD-3-Clause) AND Zlib", want: "(MIT OR BSD-3-Clause) AND Zlib"},
+		{expression: "GPL3", want: "GPL-3.0-only", warnings: []string{"Licence GPL3 is not an SPDX identifier, using GPL-3.0-only"}},
+		{expression: "Apache", want: "Apache-2.0", warnings: []string{"Licence Apache is not an SPDX identifier, using Apache-2.0"}},
+		{expression: "GPL-2.0+", want: "GPL-2.0-or-later", warnings: []string{"Licence GPL-2.0+ is not an SPDX identifier, using GPL-2.0-or-later"}},
+		{expression: "Apache-1.1+", want: "Apache-1.1+"},
+		{expression: "LicenseRef-Proprietary", want: "LicenseRef-Proprietary"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got, warnings, err := normalizeLicence(tt.expression)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.warnings, warnings)
+		})
+	}
+}
+
+func TestNormalizeLicence_Errors(t *testing.T) {
+	tests := []struct {
+		expression string
+		errMsg     string
+	}{
+		{expression: "", errMsg: "the license expression is empty"},
+		{expression: "Foo", errMsg: `unknown SPDX license identifier "Foo"`},
+		{expression: "custom", errMsg: `"custom" is not an SPDX identifier, name a custom license LicenseRef-<name> and install its license file in package()`},
+		{expression: "MIT OR", errMsg: "the license expression ends early"},
+		{expression: "MIT Apache-2.0", errMsg: `unexpected "Apache-2.0"`},
+		{expression: "(MIT OR Zlib", errMsg: "missing )"},
+		{expression: "GPL-2.0-only WITH Foo", errMsg: `unknown SPDX license exception "Foo"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, _, err := normalizeLicence(tt.expression)
+			assert.EqualError(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestGenerateWith_Licences(t *testing.T) {
+	pkg := &PkgBuild{
+		Pkgname:    "test-bin",
+		Version:    "1.0.0",
+		Pkgrel:     "1",
+		Arch:       []string{"x86_64"},
+		Licence:    []string{"GPL3", "LicenseRef-Proprietary"},
+		outputPath: t.TempDir() + "/",
+		comparator: func(context.Context, Client, PkgBuild, string) (remoteState, error) {
+			return remoteState{decision: decisionNewPackage}, nil
+		},
+		checksumCalculator: func(get func(string) ([]byte, error), sums func(string) (string, bool), sources []string) ([]string, error) {
+			return []string{}, nil
+		},
+	}
+
+	PKGBUILD, err := pkg.generateWith(context.Background(), Client{})
+	assert.NoError(t, err)
+	assert.Contains(t, PKGBUILD, "'GPL-3.0-only'")
+	assert.Equal(t, []string{
+		"Licence GPL3 is not an SPDX identifier, using GPL-3.0-only",
+		"Licence LicenseRef-Proprietary is a custom license, install a license file named after it to /usr/share/licenses/$pkgname in package()",
+	}, pkg.report.Warnings)
+}
+
+func TestCheckCustomLicences(t *testing.T) {
+	tests := []struct {
+		name     string
+		licence  string
+		PKGBUILD string
+		warnings []string
+	}{
+		{
+			name:    "installed in package",
+			licence: "LicenseRef-Proprietary",
+			PKGBUILD: `package() {
+    install -Dm644 LICENSE "$pkgdir/usr/share/licenses/$pkgname/Proprietary"
+}`,
+		},
+		{
+			name:    "installed in a split package",
+			licence: "MIT AND DocumentRef-spdx:LicenseRef-Proprietary",
+			PKGBUILD: `package_test-bin() {
+    install -Dm644 -t "$pkgdir/usr/share/licenses/$pkgname/" LICENSE-proprietary.txt
+}`,
+		},
+		{
+			name:    "generic license file",
+			licence: "LicenseRef-Proprietary",
+			PKGBUILD: `package() {
+    if [ -f "$srcdir/LICENSE" ]; then
+        install -Dm644 "$srcdir/LICENSE" "$pkgdir/usr/share/licenses/$pkgname/LICENSE"
+    fi
+}`,
+			warnings: []string{"Licence LicenseRef-Proprietary is a custom license, install a license file named after it to /usr/share/licenses/$pkgname in package()"},
+		},
+		{
+			name:    "outside of package",
+			licence: "LicenseRef-Proprietary",
+			PKGBUILD: `# license installed to /usr/share/licenses/$pkgname/Proprietary
+build() {
+    cp LICENSE "$srcdir/usr/share/licenses/$pkgname/Proprietary"
+}
+
+package() {
+    install -Dm755 test "$pkgdir/usr/bin/test"
+}`,
+			warnings: []string{"Licence LicenseRef-Proprietary is a custom license, install a license file named after it to /usr/share/licenses/$pkgname in package()"},
+		},
+		{
+			name:    "only the uninstalled one",
+			licence: "LicenseRef-Proprietary OR LicenseRef-Commercial",
+			PKGBUILD: `package() {
+    install -Dm644 COMMERCIAL "$pkgdir/usr/share/licenses/$pkgname/COMMERCIAL"
+}`,
+			warnings: []string{"Licence LicenseRef-Proprietary is a custom license, install a license file named after it to /usr/share/licenses/$pkgname in package()"},
+		},
+		{
+			name:     "no custom license",
+			licence:  "MIT",
+			PKGBUILD: "package() {\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg := &PkgBuild{Licence: []string{tt.licence}}
+			pkg.checkCustomLicences(tt.PKGBUILD)
+			assert.Equal(t, tt.warnings, pkg.warnings)
+		})
+	}
+}
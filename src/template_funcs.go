@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFuncs are the functions available to the PKGBUILD and .SRCINFO
+// templates. Functions taking the value to transform take it last, so they
+// can be used in pipelines like {{ .Version | replace "-" "_" }}.
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"join_quoted":    joinQuoted,
+		"lower":          strings.ToLower,
+		"upper":          strings.ToUpper,
+		"replace":        func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"trimPrefix":     func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix":     func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"basename":       urlBasename,
+		"sourceFilename": sourceFilename,
+		"shellQuote":     shellQuote,
+		"indent":         indent,
+		"default":        defaultValue,
+		"env":            os.Getenv,
+		"now":            templateNow,
+		"date":           formatDate,
+	}
+}
+
+// joinQuoted single quotes every item and joins them with sep.
+func joinQuoted(items []string, sep string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = "'" + item + "'"
+	}
+	return strings.Join(quoted, sep)
+}
+
+// urlBasename is the last path element of a URL, without its query or
+// fragment, or of a plain path.
+func urlBasename(source string) string {
+	if u, err := url.Parse(source); err == nil && u.Path != "" {
+		source = u.Path
+	}
+	return path.Base(source)
+}
+
+// shellQuote quotes s as a single word for bash.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// indent prefixes every non-empty line of s with spaces spaces.
+func indent(spaces int, s string) string {
+	prefix := strings.Repeat(" ", spaces)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// defaultValue is value unless it is empty, a zero value or an empty list,
+// then it is fallback.
+func defaultValue(fallback, value any) any {
+	if value == nil {
+		return fallback
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		if v.Len() == 0 {
+			return fallback
+		}
+	default:
+		if v.IsZero() {
+			return fallback
+		}
+	}
+	return value
+}
+
+// templateNow is the current time in UTC, or SOURCE_DATE_EPOCH when it is set
+// so reproducible builds template the same date.
+func templateNow() time.Time {
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		return time.Unix(epoch, 0).UTC()
+	}
+	return time.Now().UTC()
+}
+
+// formatDate formats t, or now when it is omitted, with a Go time layout.
+func formatDate(layout string, t ...time.Time) string {
+	if len(t) == 0 {
+		return templateNow().Format(layout)
+	}
+	return t[0].UTC().Format(layout)
+}
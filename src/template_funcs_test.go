@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	t.Setenv("RELEASE_AUR_TEST", "value")
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	data := map[string]any{
+		"Version": "1.2.0-rc.1",
+		"Empty":   "",
+		"List":    []string{"a", "b"},
+		"None":    []string{},
+		"Script":  "cd build\n\nmake\n",
+		"Time":    time.Date(2024, 2, 29, 23, 0, 0, 0, time.FixedZone("CET", 3600)),
+	}
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{name: "join_quoted", template: `{{ join_quoted .List " " }}`, expected: "'a' 'b'"},
+		{name: "lower", template: `{{ "Release-AUR" | lower }}`, expected: "release-aur"},
+		{name: "upper", template: `{{ "x86_64" | upper }}`, expected: "X86_64"},
+		{name: "replace", template: `{{ .Version | replace "-" "_" }}`, expected: "1.2.0_rc.1"},
+		{name: "trimPrefix", template: `{{ "v1.2.0" | trimPrefix "v" }}`, expected: "1.2.0"},
+		{name: "trimSuffix", template: `{{ "app.tar.gz" | trimSuffix ".tar.gz" }}`, expected: "app"},
+		{name: "basename of a URL", template: `{{ basename "https://example.com/download/app-linux.tar.gz?raw=1#top" }}`, expected: "app-linux.tar.gz"},
+		{name: "basename of a path", template: `{{ basename "dist/app" }}`, expected: "app"},
+		{name: "sourceFilename with a name", template: `{{ sourceFilename "app-1.0::https://example.com/app-linux" }}`, expected: "app-1.0"},
+		{name: "sourceFilename without a name", template: `{{ sourceFilename "https://example.com/app-linux" }}`, expected: "app-linux"},
+		{name: "shellQuote", template: `{{ shellQuote "it's here" }}`, expected: `'it'\''s here'`},
+		{name: "indent", template: `{{ .Script | indent 4 }}`, expected: "    cd build\n\n    make\n"},
+		{name: "default of an empty string", template: `{{ .Empty | default "none" }}`, expected: "none"},
+		{name: "default of a missing key", template: `{{ .Missing | default "none" }}`, expected: "none"},
+		{name: "default of an empty list", template: `{{ join_quoted (.None | default .List) " " }}`, expected: "'a' 'b'"},
+		{name: "default of a value", template: `{{ .Version | default "none" }}`, expected: "1.2.0-rc.1"},
+		{name: "env", template: `{{ env "RELEASE_AUR_TEST" }}`, expected: "value"},
+		{name: "now", template: `{{ now.Year }}`, expected: "2023"},
+		{name: "date of now", template: `{{ date "2006-01-02" }}`, expected: "2023-11-14"},
+		{name: "date of a time in UTC", template: `{{ date "2006-01-02 15:04" .Time }}`, expected: "2024-02-29 22:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New(tt.name).Funcs(templateFuncs()).Parse(tt.template)
+			assert.NoError(t, err)
+			var buf bytes.Buffer
+			assert.NoError(t, tmpl.Execute(&buf, data))
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+}
+
+func TestTemplateNow_WithoutSourceDateEpoch(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	assert.WithinDuration(t, time.Now(), templateNow(), time.Minute)
+	assert.Equal(t, time.UTC, templateNow().Location())
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// builtinTemplates holds the .SRCINFO template and the named PKGBUILD
+// templates, every one of them fills the blocks of the layout in
+// templates/base.tmpl.
+//
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+const (
+	templateLayout  = "base.tmpl"
+	srcinfoTemplate = "srcinfo.tmpl"
+	installTemplate = "install.tmpl"
+)
+
+func builtinTemplateNames() []string {
+	files, _ := fs.Glob(builtinTemplates, "templates/*.tmpl")
+	names := []string{}
+	for _, file := range files {
+		if name := path.Base(file); name != templateLayout && name != srcinfoTemplate && name != installTemplate {
+			names = append(names, strings.TrimSuffix(name, ".tmpl"))
+		}
+	}
+	return names
+}
+
+// templatePath resolves a custom template relative to the working directory,
+// the workspace in the action. A template missing there is looked up in
+// templateDir, which paths were relative to before the templates were built in.
+func templatePath(name, templateDir string) string {
+	if name == "" || templateDir == "" || filepath.IsAbs(name) {
+		return name
+	}
+	if _, err := os.Stat(name); err == nil {
+		return name
+	}
+	fallback := filepath.Join(templateDir, name)
+	if _, err := os.Stat(fallback); err != nil {
+		return name
+	}
+	slog.Warn("Template found relative to the action, template paths are relative to the workspace", "template", name, "path", fallback)
+	return fallback
+}
+
+func validateTemplateName(name string) error {
+	if name == "" || slices.Contains(builtinTemplateNames(), name) {
+		return nil
+	}
+	return invalid("templateName", "Unknown Template %q, expected one of: %s", name, strings.Join(builtinTemplateNames(), ", "))
+}
+
+// defaultTemplateName is the built-in template of the mode, source-<preset>
+// in source mode and bin-single otherwise.
+func (pkgbuild PkgBuild) defaultTemplateName() string {
+	if pkgbuild.Mode == "source" {
+		return "source-" + pkgbuild.BuildPreset
+	}
+	return "bin-single"
+}
+
+// parsePkgbuildTemplate adds the PKGBUILD template to tmpl and returns the
+// name to execute. A custom template path takes precedence, otherwise the
+// built-in template is parsed after the layout and the template override after
+// both, so each redefines the blocks of the former.
+func (pkgbuild PkgBuild) parsePkgbuildTemplate(tmpl *template.Template) (*template.Template, string, error) {
+	if pkgbuild.pkgbuildTemplatePath != "" {
+		tmpl, err := tmpl.ParseFiles(pkgbuild.pkgbuildTemplatePath)
+		return tmpl, filepath.Base(pkgbuild.pkgbuildTemplatePath), err
+	}
+
+	name := pkgbuild.templateName
+	if name == "" {
+		name = pkgbuild.defaultTemplateName()
+	}
+	tmpl, err := tmpl.ParseFS(builtinTemplates, "templates/"+templateLayout)
+	if err != nil {
+		return nil, "", err
+	}
+	if tmpl, err = tmpl.ParseFS(builtinTemplates, "templates/"+name+".tmpl"); err != nil {
+		return nil, "", err
+	}
+	if pkgbuild.templateOverride != "" {
+		if tmpl, err = tmpl.ParseFiles(pkgbuild.templateOverride); err != nil {
+			return nil, "", err
+		}
+	}
+	return tmpl, templateLayout, nil
+}
+
+func (pkgbuild PkgBuild) parseSrcinfoTemplate(tmpl *template.Template) (*template.Template, string, error) {
+	if pkgbuild.srcInfoTemplatePath != "" {
+		tmpl, err := tmpl.ParseFiles(pkgbuild.srcInfoTemplatePath)
+		return tmpl, filepath.Base(pkgbuild.srcInfoTemplatePath), err
+	}
+	tmpl, err := tmpl.ParseFS(builtinTemplates, "templates/"+srcinfoTemplate)
+	return tmpl, srcinfoTemplate, err
+}
+
+func (pkgbuild PkgBuild) parseInstallTemplate(tmpl *template.Template) (*template.Template, string, error) {
+	if pkgbuild.installTemplatePath != "" {
+		tmpl, err := tmpl.ParseFiles(pkgbuild.installTemplatePath)
+		return tmpl, filepath.Base(pkgbuild.installTemplatePath), err
+	}
+	tmpl, err := tmpl.ParseFS(builtinTemplates, "templates/"+installTemplate)
+	return tmpl, installTemplate, err
+}
+
+// dumpTemplates writes the built-in templates to dir as a starting point for
+// a template override or a custom template, it refuses to overwrite a file.
+func dumpTemplates(dir string) error {
+	files, err := fs.Glob(builtinTemplates, "templates/*.tmpl")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	for _, file := range files {
+		content, err := builtinTemplates.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, path.Base(file))
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to create template %s: %w", target, err)
+		}
+		if _, err := out.Write(content); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
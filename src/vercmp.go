@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// vercmp compares two [epoch:]pkgver[-pkgrel] versions the way pacman's
+// vercmp does, it returns -1, 0 or 1. A missing epoch is 0 and a pkgrel is
+// only compared when both versions have one.
+func vercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	epochA, versionA, releaseA := splitEVR(a)
+	epochB, versionB, releaseB := splitEVR(b)
+	if result := rpmvercmp(epochA, epochB); result != 0 {
+		return result
+	}
+	if result := rpmvercmp(versionA, versionB); result != 0 {
+		return result
+	}
+	if releaseA == "" || releaseB == "" {
+		return 0
+	}
+	return rpmvercmp(releaseA, releaseB)
+}
+
+func splitEVR(version string) (epoch, pkgver, pkgrel string) {
+	epoch = "0"
+	if index := strings.IndexFunc(version, func(r rune) bool { return !unicode.IsDigit(r) }); index > 0 && version[index] == ':' {
+		epoch, version = version[:index], version[index+1:]
+	} else if index == 0 && version[0] == ':' {
+		version = version[1:]
+	}
+	if index := strings.LastIndex(version, "-"); index >= 0 {
+		version, pkgrel = version[:index], version[index+1:]
+	}
+	return epoch, version, pkgrel
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// rpmvercmp compares the segments of two versions, numeric segments are newer
+// than alphabetic ones and a trailing alphabetic segment is older than none,
+// so 1.0alpha < 1.0 < 1.0.1.
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		startA, startB := i, j
+		for i < len(a) && !isAlnum(a[i]) {
+			i++
+		}
+		for j < len(b) && !isAlnum(b[j]) {
+			j++
+		}
+		if i >= len(a) || j >= len(b) {
+			break
+		}
+		// a separator beats no separator, 1.0.1 > 1.0a
+		if i-startA != j-startB {
+			if i-startA < j-startB {
+				return -1
+			}
+			return 1
+		}
+
+		startA, startB = i, j
+		numeric := isDigit(a[i])
+		if numeric {
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+		} else {
+			for i < len(a) && isAlnum(a[i]) && !isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isAlnum(b[j]) && !isDigit(b[j]) {
+				j++
+			}
+		}
+		segmentA, segmentB := a[startA:i], b[startB:j]
+		if segmentB == "" {
+			// segments of different types, numeric is newer
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+		if numeric {
+			segmentA = strings.TrimLeft(segmentA, "0")
+			segmentB = strings.TrimLeft(segmentB, "0")
+			if len(segmentA) != len(segmentB) {
+				if len(segmentA) < len(segmentB) {
+					return -1
+				}
+				return 1
+			}
+		}
+		if result := strings.Compare(segmentA, segmentB); result != 0 {
+			return result
+		}
+	}
+
+	restA, restB := a[i:], b[j:]
+	if restA == "" && restB == "" {
+		return 0
+	}
+	// the version with an alphabetic segment left is older, 1.0alpha < 1.0,
+	// otherwise the one with anything left is newer, 1.0.1 > 1.0
+	if restA == "" && !isAlphaStart(restB) || isAlphaStart(restA) {
+		return -1
+	}
+	return 1
+}
+
+func isAlphaStart(rest string) bool {
+	return rest != "" && isAlnum(rest[0]) && !isDigit(rest[0])
+}
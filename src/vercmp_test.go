@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVercmp(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "1.0.0", b: "1.0.0", expected: 0},
+		{a: "1.0", b: "1.0.0", expected: -1},
+		{a: "1.0.1", b: "1.0.0", expected: 1},
+		{a: "1.10", b: "1.9", expected: 1},
+		{a: "1.01", b: "1.1", expected: 0},
+		{a: "1.0alpha", b: "1.0", expected: -1},
+		{a: "1.0a", b: "1.0b", expected: -1},
+		{a: "1.0a", b: "1.0.1", expected: -1},
+		{a: "1.0rc1", b: "1.0", expected: -1},
+		{a: "1.0", b: "1.0rc1", expected: 1},
+		{a: "1.0.a", b: "1.0.1", expected: -1},
+		{a: "1.0-2", b: "1.0-1", expected: 1},
+		{a: "1.0-1", b: "1.0", expected: 0},
+		{a: "1:1.0", b: "2.0", expected: 1},
+		{a: "0:2.0", b: "2.0", expected: 0},
+		{a: "1:1.0-1", b: "1:1.0-2", expected: -1},
+		{a: "2.0", b: "1:1.0", expected: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.expected, vercmp(tt.a, tt.b))
+			assert.Equal(t, -tt.expected, vercmp(tt.b, tt.a))
+		})
+	}
+}